@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type ActionToken struct {
+	Hash       []byte
+	UserId     int64
+	Action     string
+	ResourceId string
+	ExpiresAt  time.Time
+	UsedAt     time.Time
+}
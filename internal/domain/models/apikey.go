@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	Id         string
+	UserId     int64
+	Label      string
+	SecretHash []byte
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
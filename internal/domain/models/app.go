@@ -1,7 +1,44 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
 type App struct {
 	Id     int32
 	Name   string
 	Secret string
+	// PreviousSecret is the secret being rotated out. Tokens signed with it
+	// still validate, but clients are advised to refresh them.
+	PreviousSecret string
+	// BindFingerprint binds issued tokens to the client fingerprint.
+	BindFingerprint bool
+	// AccessTTL and RefreshTTL override the service defaults when set.
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// RequireEmailVerification rolls back registrations whose verification
+	// email could not be sent.
+	RequireEmailVerification bool
+	// RoleScopes maps a user role to the scopes it grants in this app.
+	RoleScopes map[string][]string
+	// MaxSessions limits the active sessions per user, zero means no limit.
+	// SessionEviction names the policy applied when it is reached, "reject"
+	// by default.
+	MaxSessions     int
+	SessionEviction string
+	// TokensValidAfter rejects this app's tokens issued before it.
+	TokensValidAfter time.Time
+}
+
+// ScopesFor returns the sorted, deduplicated scopes granted by roles.
+func (a *App) ScopesFor(roles []string) []string {
+	var scopes []string
+	for _, role := range roles {
+		scopes = append(scopes, a.RoleScopes[role]...)
+	}
+
+	slices.Sort(scopes)
+
+	return slices.Compact(scopes)
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type AuditEvent struct {
+	// Seq is assigned by the store and increases with every event.
+	Seq        int64
+	Type       string
+	UserId     int64
+	AppId      int32
+	IP         string
+	OccurredAt time.Time
+	Details    map[string]string
+}
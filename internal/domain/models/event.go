@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Event struct {
+	// Seq is the event's position in the log, see EventStore.
+	Seq        int64
+	Type       string
+	UserId     int64
+	AppId      int32
+	SessionId  string
+	TokenId    string
+	OccurredAt time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotentLogin is a login result stored under a hashed idempotency key,
+// so a retried login returns the same token. The token is only kept
+// sealed, with a key derived from the idempotency key, which is not stored.
+type IdempotentLogin struct {
+	KeyHash          []byte
+	SealedTokens     []byte
+	TokenExpiresAt   time.Time
+	SessionId        string
+	SessionExpiresAt time.Time
+	TokenId          string
+	ExpiresAt        time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type Invitation struct {
+	Hash      []byte
+	InviterId int64
+	Email     string
+	Roles     []string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type RotationRecord struct {
+	SessionId string
+	TokenId   string
+	IssuedAt  time.Time
+	RotatedAt time.Time
+	Reason    string
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Session struct {
+	Id             string
+	UserId         int64
+	AppId          int32
+	CreatedAt      time.Time
+	LastActivityAt time.Time
+	ExpiresAt      time.Time
+	RevokedAt      time.Time
+}
@@ -1,7 +1,25 @@
 package models
 
+import "time"
+
 type User struct {
-	Id       int32
-	Name     string
-	PassHash []byte
+	Id            int32
+	Name          string
+	PassHash      []byte
+	DisplayName   string
+	Metadata      map[string]string
+	Roles         []string
+	TenantID      string
+	EmailVerified bool
+	// Version is bumped on every update, for optimistic concurrency.
+	Version           int64
+	PasswordChangedAt time.Time
+	// NeedsRehash is set to upgrade the hash on the next login.
+	NeedsRehash bool
+	UpdatedAt   time.Time
+	// TokenTTL overrides the access token TTL of the user's apps, e.g. to
+	// give admins shorter sessions.
+	TokenTTL *time.Duration
+	// DeletedAt is set when the user is soft-deleted.
+	DeletedAt time.Time
 }
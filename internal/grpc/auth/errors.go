@@ -0,0 +1,87 @@
+package authgrpc
+
+import (
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/internal/services/auth"
+)
+
+// errorDomain is the ErrorInfo domain of every error reason.
+const errorDomain = "sso"
+
+// Error reasons attached to gRPC errors as ErrorInfo details. They are
+// stable, clients may branch on them.
+const (
+	ReasonInvalidCredentials       = "AUTH_INVALID_CREDENTIALS"
+	ReasonInvalidApp               = "AUTH_INVALID_APP"
+	ReasonUserExists               = "AUTH_USER_EXISTS"
+	ReasonUserNotFound             = "AUTH_USER_NOT_FOUND"
+	ReasonPasswordTooSimilar       = "AUTH_PASSWORD_TOO_SIMILAR"
+	ReasonWeakPassword             = "AUTH_WEAK_PASSWORD"
+	ReasonCommonPassword           = "AUTH_COMMON_PASSWORD"
+	ReasonUndeliverableEmail       = "AUTH_UNDELIVERABLE_EMAIL"
+	ReasonVerificationSendFailed   = "AUTH_VERIFICATION_SEND_FAILED"
+	ReasonRateLimited              = "AUTH_RATE_LIMITED"
+	ReasonIPBlocked                = "AUTH_IP_BLOCKED"
+	ReasonBusy                     = "AUTH_BUSY"
+	ReasonCaptchaRequired          = "AUTH_CAPTCHA_REQUIRED"
+	ReasonInvalidCaptcha           = "AUTH_INVALID_CAPTCHA"
+	ReasonFingerprintRequired      = "AUTH_FINGERPRINT_REQUIRED"
+	ReasonSessionLimitReached      = "AUTH_SESSION_LIMIT_REACHED"
+	ReasonPasswordLoginUnavailable = "AUTH_PASSWORD_LOGIN_UNAVAILABLE"
+	ReasonInternal                 = "AUTH_INTERNAL"
+)
+
+type errorMapping struct {
+	err     error
+	code    codes.Code
+	reason  string
+	message string
+}
+
+// errorMappings maps service errors to their status, checked in order.
+var errorMappings = []errorMapping{
+	{auth.ErrInvalidCredentials, codes.Unauthenticated, ReasonInvalidCredentials, "invalid email or password"},
+	{auth.ErrInvalidAppID, codes.InvalidArgument, ReasonInvalidApp, "invalid app id"},
+	{auth.ErrUserExists, codes.AlreadyExists, ReasonUserExists, "user already exists"},
+	{auth.ErrUserNotFound, codes.NotFound, ReasonUserNotFound, "user not found"},
+	{auth.ErrPasswordTooSimilar, codes.InvalidArgument, ReasonPasswordTooSimilar, "password is too similar to email"},
+	{auth.ErrWeakPassword, codes.InvalidArgument, ReasonWeakPassword, "password does not meet the policy"},
+	{auth.ErrCommonPassword, codes.InvalidArgument, ReasonCommonPassword, "password is too common"},
+	{auth.ErrUndeliverableEmail, codes.InvalidArgument, ReasonUndeliverableEmail, "email domain does not accept mail"},
+	{auth.ErrVerificationSendFailed, codes.Unavailable, ReasonVerificationSendFailed, "failed to send verification email"},
+	{auth.ErrRateLimited, codes.ResourceExhausted, ReasonRateLimited, "too many requests"},
+	{auth.ErrIPBlocked, codes.PermissionDenied, ReasonIPBlocked, "ip address is blocked"},
+	{auth.ErrTimeout, codes.Unavailable, ReasonBusy, "server is busy"},
+	{auth.ErrCaptchaRequired, codes.FailedPrecondition, ReasonCaptchaRequired, "captcha required"},
+	{auth.ErrInvalidCaptcha, codes.InvalidArgument, ReasonInvalidCaptcha, "invalid captcha"},
+	{auth.ErrFingerprintRequired, codes.FailedPrecondition, ReasonFingerprintRequired, "client fingerprint required"},
+	{auth.ErrSessionLimitReached, codes.ResourceExhausted, ReasonSessionLimitReached, "too many active sessions"},
+	{auth.ErrPasswordLoginUnavailable, codes.FailedPrecondition, ReasonPasswordLoginUnavailable, "password login unavailable, use social login"},
+}
+
+// toStatus converts a service error to a gRPC error carrying an ErrorInfo
+// detail with a stable reason. Unknown errors become Internal.
+func toStatus(err error) error {
+	mapping := errorMapping{code: codes.Internal, reason: ReasonInternal, message: "internal error"}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			mapping = m
+
+			break
+		}
+	}
+
+	st, detailErr := status.New(mapping.code, mapping.message).WithDetails(&errdetails.ErrorInfo{
+		Reason: mapping.reason,
+		Domain: errorDomain,
+	})
+	if detailErr != nil {
+		return status.Error(mapping.code, mapping.message)
+	}
+
+	return st.Err()
+}
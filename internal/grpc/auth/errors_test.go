@@ -0,0 +1,104 @@
+package authgrpc
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/internal/services/auth"
+	"testing"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name:       "invalid credentials",
+			err:        fmt.Errorf("auth.Login: %w", auth.ErrInvalidCredentials),
+			wantCode:   codes.Unauthenticated,
+			wantReason: ReasonInvalidCredentials,
+		},
+		{
+			name:       "user exists",
+			err:        fmt.Errorf("auth.RegisterNewUser: %w", auth.ErrUserExists),
+			wantCode:   codes.AlreadyExists,
+			wantReason: ReasonUserExists,
+		},
+		{
+			name:       "rate limited",
+			err:        fmt.Errorf("auth.Login: %w", auth.ErrRateLimited),
+			wantCode:   codes.ResourceExhausted,
+			wantReason: ReasonRateLimited,
+		},
+		{
+			name:       "busy",
+			err:        fmt.Errorf("auth.Login: %w", auth.ErrTimeout),
+			wantCode:   codes.Unavailable,
+			wantReason: ReasonBusy,
+		},
+		{
+			name:       "password login unavailable",
+			err:        fmt.Errorf("auth.Login: %w", auth.ErrPasswordLoginUnavailable),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: ReasonPasswordLoginUnavailable,
+		},
+		{
+			name:       "unknown error",
+			err:        errors.New("connection refused"),
+			wantCode:   codes.Internal,
+			wantReason: ReasonInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(toStatus(tt.err))
+			if !ok {
+				t.Fatalf("toStatus() returned a non-status error")
+			}
+
+			if st.Code() != tt.wantCode {
+				t.Fatalf("code = %v, want %v", st.Code(), tt.wantCode)
+			}
+
+			details := st.Details()
+			if len(details) != 1 {
+				t.Fatalf("details = %v, want one ErrorInfo", details)
+			}
+
+			info, ok := details[0].(*errdetails.ErrorInfo)
+			if !ok {
+				t.Fatalf("detail = %T, want *errdetails.ErrorInfo", details[0])
+			}
+
+			if info.GetReason() != tt.wantReason || info.GetDomain() != errorDomain {
+				t.Fatalf("ErrorInfo = %s/%s, want %s/%s", info.GetDomain(), info.GetReason(), errorDomain, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestToStatusHidesInternalErrors(t *testing.T) {
+	st, _ := status.FromError(toStatus(errors.New("dial tcp 10.0.0.1:5432: connection refused")))
+
+	if st.Message() != "internal error" {
+		t.Fatalf("message = %q, want %q", st.Message(), "internal error")
+	}
+}
+
+func TestErrorMappingsHaveUniqueReasons(t *testing.T) {
+	seen := make(map[string]bool)
+
+	for _, m := range errorMappings {
+		if m.reason == "" || seen[m.reason] {
+			t.Errorf("reason %q of %v is empty or reused", m.reason, m.err)
+		}
+
+		seen[m.reason] = true
+	}
+}
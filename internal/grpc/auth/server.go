@@ -5,7 +5,12 @@ import (
 	ssov1 "github.com/ShiroyamaY/protos/gen/go/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"net"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/services/auth"
 )
 
 const (
@@ -17,11 +22,13 @@ type Auth interface {
 		email string,
 		password string,
 		appID int32,
-	) (token string, err error)
+		opts ...auth.LoginOption,
+	) (result *auth.LoginResult, err error)
 	RegisterNewUser(
 		ctx context.Context,
 		email string,
 		password string,
+		opts ...auth.RegisterOption,
 	) (userID int64, err error)
 	IsAdmin(ctx context.Context, userID int64) (isAdmin bool, err error)
 }
@@ -43,10 +50,9 @@ func (server *serverAPI) Register(
 		return nil, err
 	}
 
-	userId, err := server.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
-
+	userId, err := server.auth.RegisterNewUser(withClientInfo(ctx), req.GetEmail(), req.GetPassword())
 	if err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
 	}
 
 	return &ssov1.RegisterResponse{UserId: userId}, nil
@@ -60,12 +66,19 @@ func (server *serverAPI) Login(
 		return nil, err
 	}
 
-	token, err := server.auth.Login(ctx, req.GetEmail(), req.GetPassword(), req.GetAppId())
+	var opts []auth.LoginOption
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(captchaHeader); len(values) > 0 {
+			opts = append(opts, auth.LoginWithCaptcha(values[0]))
+		}
+	}
+
+	result, err := server.auth.Login(withClientInfo(ctx), req.GetEmail(), req.GetPassword(), req.GetAppId(), opts...)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
 	}
 
-	return &ssov1.LoginResponse{Token: token}, nil
+	return &ssov1.LoginResponse{Token: result.Token}, nil
 }
 
 func (server *serverAPI) IsAdmin(
@@ -78,12 +91,41 @@ func (server *serverAPI) IsAdmin(
 
 	isAdmin, err := server.auth.IsAdmin(ctx, req.GetUserId())
 	if err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatus(err)
 	}
 
 	return &ssov1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
 
+const (
+	// fingerprintHeader carries the client fingerprint computed by the edge.
+	fingerprintHeader = "x-client-fingerprint"
+	// captchaHeader carries the client's CAPTCHA response for Login.
+	captchaHeader = "x-captcha-token"
+)
+
+// withClientInfo copies the caller address from the gRPC peer and the client
+// fingerprint from the request metadata into ctx.
+func withClientInfo(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(fingerprintHeader); len(values) > 0 {
+			ctx = clientinfo.WithFingerprint(ctx, values[0])
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ctx
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	return clientinfo.WithIP(ctx, host)
+}
+
 func validateLogin(req *ssov1.LoginRequest) error {
 	if req.GetEmail() == "" {
 		return status.Error(codes.InvalidArgument, "email is required")
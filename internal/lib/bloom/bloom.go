@@ -0,0 +1,119 @@
+// Package bloom implements a fixed-size Bloom filter.
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+var ErrInvalidEncoding = errors.New("invalid bloom filter encoding")
+
+// maxHashes bounds the hash count, and with it the work per lookup. It is
+// reached at false-positive rates around 1e-10, far below any useful one.
+const maxHashes = 32
+
+// Filter is a Bloom filter. It is not safe for concurrent writes.
+type Filter struct {
+	bits   []uint64
+	m      uint64
+	hashes uint64
+}
+
+// New returns a filter sized for n items at the given false-positive rate.
+func New(n int, fpRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Min(maxHashes, math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2))))
+
+	return &Filter{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: k,
+	}
+}
+
+// Add inserts data into the filter.
+func (f *Filter) Add(data []byte) {
+	h1, h2 := hash(data)
+
+	for i := range f.hashes {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain reports whether data may have been added. False positives are
+// possible, false negatives are not.
+func (f *Filter) MayContain(data []byte) bool {
+	h1, h2 := hash(data)
+
+	for i := range f.hashes {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MarshalBinary encodes the filter as its size and hash count followed by
+// its bits, all big-endian, so it can be shipped to other processes.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 16+8*len(f.bits))
+	data = binary.BigEndian.AppendUint64(data, f.m)
+	data = binary.BigEndian.AppendUint64(data, f.hashes)
+
+	for _, word := range f.bits {
+		data = binary.BigEndian.AppendUint64(data, word)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary. Filters with
+// more than maxHashes hashes or a size that doesn't match their bits are
+// rejected, since the data may come from another process.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 || len(data)%8 != 0 {
+		return ErrInvalidEncoding
+	}
+
+	m := binary.BigEndian.Uint64(data)
+	hashes := binary.BigEndian.Uint64(data[8:])
+	words := data[16:]
+	n := uint64(len(words) / 8)
+
+	// m must need exactly n words. Comparing without rounding m up avoids
+	// an overflow for sizes near the uint64 maximum.
+	if hashes == 0 || hashes > maxHashes || n == 0 || m > 64*n || m <= 64*(n-1) {
+		return ErrInvalidEncoding
+	}
+
+	bits := make([]uint64, n)
+	for i := range bits {
+		bits[i] = binary.BigEndian.Uint64(words[8*i:])
+	}
+
+	f.bits, f.m, f.hashes = bits, m, hashes
+
+	return nil
+}
+
+// hash derives the two base hashes for double hashing from FNV-128a.
+func hash(data []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(data)
+	sum := h.Sum(nil)
+
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
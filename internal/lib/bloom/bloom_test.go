@@ -0,0 +1,174 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFilterNoFalseNegatives(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		fpRate float64
+	}{
+		{name: "small", n: 10, fpRate: 0.01},
+		{name: "large", n: 10000, fpRate: 0.001},
+		{name: "invalid rate uses default", n: 100, fpRate: 2},
+		{name: "zero items", n: 0, fpRate: 0.01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(tt.n, tt.fpRate)
+
+			for i := range max(tt.n, 1) {
+				f.Add(fmt.Appendf(nil, "item-%d", i))
+			}
+
+			for i := range max(tt.n, 1) {
+				if !f.MayContain(fmt.Appendf(nil, "item-%d", i)) {
+					t.Fatalf("MayContain(item-%d) = false after Add", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterFalsePositiveRate(t *testing.T) {
+	const (
+		n      = 5000
+		fpRate = 0.01
+		probes = 20000
+	)
+
+	f := New(n, fpRate)
+	for i := range n {
+		f.Add(fmt.Appendf(nil, "member-%d", i))
+	}
+
+	positives := 0
+	for i := range probes {
+		if f.MayContain(fmt.Appendf(nil, "other-%d", i)) {
+			positives++
+		}
+	}
+
+	// Allow three times the target rate for randomness in the hashes.
+	if got := float64(positives) / probes; got > 3*fpRate {
+		t.Fatalf("false-positive rate = %.4f, want at most %.4f", got, 3*fpRate)
+	}
+}
+
+func TestNewClampsHashCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		fpRate float64
+		want   uint64
+	}{
+		{name: "typical rate", fpRate: 0.01, want: 7},
+		{name: "tiny rate", fpRate: 1e-30, want: maxHashes},
+		{name: "high rate", fpRate: 0.99, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(1000, tt.fpRate).hashes; got != tt.want {
+				t.Fatalf("hashes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRoundTrip(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := range 1000 {
+		f.Add(fmt.Appendf(nil, "item-%d", i))
+	}
+
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	decoded := new(Filter)
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if decoded.m != f.m || decoded.hashes != f.hashes {
+		t.Fatalf("decoded size and hashes = %d, %d, want %d, %d", decoded.m, decoded.hashes, f.m, f.hashes)
+	}
+
+	for i := range 2000 {
+		item := fmt.Appendf(nil, "item-%d", i)
+		if decoded.MayContain(item) != f.MayContain(item) {
+			t.Fatalf("MayContain(%s) differs after the round trip", item)
+		}
+	}
+
+	again, _ := decoded.MarshalBinary()
+	if !bytes.Equal(again, data) {
+		t.Fatalf("re-encoding the decoded filter changed it")
+	}
+}
+
+// encode builds an encoding with the given header and number of zero words.
+func encode(m, hashes uint64, words int) []byte {
+	data := binary.BigEndian.AppendUint64(nil, m)
+	data = binary.BigEndian.AppendUint64(data, hashes)
+
+	return append(data, make([]byte, 8*words)...)
+}
+
+func TestFilterUnmarshalBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "one word", data: encode(64, 3, 1)},
+		{name: "partial last word", data: encode(65, 3, 2)},
+		{name: "max hashes", data: encode(64, maxHashes, 1)},
+		{name: "empty", data: nil, wantErr: ErrInvalidEncoding},
+		{name: "short header", data: make([]byte, 8), wantErr: ErrInvalidEncoding},
+		{name: "truncated word", data: encode(64, 3, 1)[:20], wantErr: ErrInvalidEncoding},
+		{name: "no words", data: encode(64, 3, 0), wantErr: ErrInvalidEncoding},
+		{name: "zero hashes", data: encode(64, 0, 1), wantErr: ErrInvalidEncoding},
+		{name: "too many hashes", data: encode(64, maxHashes+1, 1), wantErr: ErrInvalidEncoding},
+		{name: "size beyond words", data: encode(65, 3, 1), wantErr: ErrInvalidEncoding},
+		{name: "size short of words", data: encode(64, 3, 2), wantErr: ErrInvalidEncoding},
+		{name: "zero size", data: encode(0, 3, 1), wantErr: ErrInvalidEncoding},
+		{name: "size near overflow", data: encode(math.MaxUint64, 3, 1), wantErr: ErrInvalidEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(10, 0.01)
+			before, _ := f.MarshalBinary()
+
+			err := f.UnmarshalBinary(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnmarshalBinary() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				// A rejected encoding leaves the filter as it was.
+				if after, _ := f.MarshalBinary(); !bytes.Equal(after, before) {
+					t.Fatalf("UnmarshalBinary() changed the filter on error")
+				}
+
+				return
+			}
+
+			// Decoded filters must be usable without panicking.
+			f.Add([]byte("item"))
+			if !f.MayContain([]byte("item")) {
+				t.Fatalf("MayContain(item) = false after Add")
+			}
+		})
+	}
+}
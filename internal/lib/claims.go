@@ -0,0 +1,80 @@
+package jwt
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// ClaimEquals reports whether the claim key equals want. Numbers compare by
+// value regardless of their Go type, since decoded JSON numbers are float64.
+// Values of different kinds, such as "1" and 1, are never equal.
+func ClaimEquals(claims map[string]any, key string, want any) bool {
+	got, ok := claims[key]
+	if !ok {
+		return false
+	}
+
+	if g, ok := toFloat(got); ok {
+		w, ok := toFloat(want)
+
+		return ok && g == w
+	}
+
+	switch g := got.(type) {
+	case string:
+		w, ok := want.(string)
+
+		return ok && g == w
+	case bool:
+		w, ok := want.(bool)
+
+		return ok && g == w
+	}
+
+	return false
+}
+
+// ClaimContains reports whether the array claim key contains want. A string
+// claim is treated as a space-separated list, as used by "scope".
+func ClaimContains(claims map[string]any, key string, want string) bool {
+	switch got := claims[key].(type) {
+	case []any:
+		for _, v := range got {
+			if s, ok := v.(string); ok && s == want {
+				return true
+			}
+		}
+	case []string:
+		return slices.Contains(got, want)
+	case string:
+		return slices.Contains(strings.Fields(got), want)
+	}
+
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+
+		return f, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestClaimEquals(t *testing.T) {
+	claims := map[string]any{
+		"app_id":   float64(1),
+		"email":    "user@example.com",
+		"verified": true,
+		"number":   json.Number("42"),
+		"roles":    []any{"admin"},
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want any
+		ok   bool
+	}{
+		{name: "float equals int", key: "app_id", want: 1, ok: true},
+		{name: "float equals int32", key: "app_id", want: int32(1), ok: true},
+		{name: "float differs", key: "app_id", want: 2, ok: false},
+		{name: "number never equals string", key: "app_id", want: "1", ok: false},
+		{name: "string equals", key: "email", want: "user@example.com", ok: true},
+		{name: "string differs", key: "email", want: "other@example.com", ok: false},
+		{name: "bool equals", key: "verified", want: true, ok: true},
+		{name: "bool never equals string", key: "verified", want: "true", ok: false},
+		{name: "json number", key: "number", want: int64(42), ok: true},
+		{name: "missing claim", key: "tenant_id", want: "", ok: false},
+		{name: "arrays are not compared", key: "roles", want: []any{"admin"}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClaimEquals(claims, tt.key, tt.want); got != tt.ok {
+				t.Fatalf("ClaimEquals(%q, %v) = %v, want %v", tt.key, tt.want, got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestClaimContains(t *testing.T) {
+	claims := map[string]any{
+		"roles":    []any{"admin", 1, "editor"},
+		"resource": []string{"https://api.example.com"},
+		"scope":    "read write",
+		"app_id":   float64(1),
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want string
+		ok   bool
+	}{
+		{name: "decoded array", key: "roles", want: "editor", ok: true},
+		{name: "decoded array without value", key: "roles", want: "viewer", ok: false},
+		{name: "string slice", key: "resource", want: "https://api.example.com", ok: true},
+		{name: "space-separated string", key: "scope", want: "write", ok: true},
+		{name: "no substring matches", key: "scope", want: "rea", ok: false},
+		{name: "not a list", key: "app_id", want: "1", ok: false},
+		{name: "missing claim", key: "groups", want: "admin", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClaimContains(claims, tt.key, tt.want); got != tt.ok {
+				t.Fatalf("ClaimContains(%q, %q) = %v, want %v", tt.key, tt.want, got, tt.ok)
+			}
+		})
+	}
+}
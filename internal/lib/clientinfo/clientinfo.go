@@ -0,0 +1,49 @@
+// Package clientinfo carries details about the calling client through a
+// request context, so services don't depend on the transport layer.
+package clientinfo
+
+import "context"
+
+type ipKey struct{}
+
+// WithIP returns a copy of ctx carrying the client IP address.
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ipKey{}, ip)
+}
+
+// IP returns the client IP address stored in ctx, if any.
+func IP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(ipKey{}).(string)
+
+	return ip, ok && ip != ""
+}
+
+type fingerprintKey struct{}
+
+// WithFingerprint returns a copy of ctx carrying the client fingerprint,
+// a hash of TLS or device characteristics computed by the transport.
+func WithFingerprint(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, fingerprintKey{}, fingerprint)
+}
+
+// Fingerprint returns the client fingerprint stored in ctx, if any.
+func Fingerprint(ctx context.Context) (string, bool) {
+	fingerprint, ok := ctx.Value(fingerprintKey{}).(string)
+
+	return fingerprint, ok && fingerprint != ""
+}
+
+type userIDKey struct{}
+
+// WithUserID returns a copy of ctx carrying the ID of the authenticated
+// caller. The transport sets it after verifying the caller's credentials.
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID returns the ID of the authenticated caller stored in ctx, if any.
+func UserID(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int64)
+
+	return userID, ok
+}
@@ -1,20 +1,135 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt"
+	"maps"
+	"slices"
 	"sso/internal/domain/models"
+	"strings"
 	"time"
 )
 
-func NewToken(user *models.User, app *models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// Claim names used in tokens.
+const (
+	ClaimUserID       = "userId"
+	ClaimEmail        = "email"
+	ClaimExpiresAt    = "exp"
+	ClaimIssuedAt     = "iat"
+	ClaimAppID        = "app_id"
+	ClaimSessionID    = "sid"
+	ClaimTokenID      = "jti"
+	ClaimConfirmation = "cnf"
+	ClaimResource     = "resource"
+	ClaimRoles        = "roles"
+	ClaimScope        = "scope"
+	ClaimIssuer       = "iss"
+	ClaimTenantID     = "tenant_id"
+	ClaimVersion      = "ver"
+	// ClaimPseudonymousID carries the user's pseudonymous analytics ID.
+	ClaimPseudonymousID = "psid"
+	// ClaimAuthorizedParty names the app a downstream token was exchanged from.
+	ClaimAuthorizedParty = "azp"
+)
+
+// confirmationFingerprint is the member of the "cnf" claim holding the
+// client fingerprint the token is bound to.
+const confirmationFingerprint = "fp"
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Option adds optional claims to a token.
+type Option func(claims jwt.MapClaims)
+
+// WithSessionID binds the token to a login session via the "sid" claim.
+func WithSessionID(sessionID string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimSessionID] = sessionID
+	}
+}
+
+// WithTokenID sets the unique token identifier, the "jti" claim.
+func WithTokenID(tokenID string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimTokenID] = tokenID
+	}
+}
+
+// WithFingerprint binds the token to a client fingerprint via the "cnf" claim.
+func WithFingerprint(fingerprint string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimConfirmation] = map[string]any{confirmationFingerprint: fingerprint}
+	}
+}
+
+// WithResources restricts the token to the given resource indicators.
+func WithResources(resources []string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimResource] = resources
+	}
+}
 
-	claims := token.Claims.(jwt.MapClaims)
+// WithRoles adds the user's roles.
+func WithRoles(roles []string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimRoles] = roles
+	}
+}
 
-	claims["userId"] = user.Id
-	claims["email"] = user.Name
-	claims["exp"] = time.Now().Add(duration).Unix()
-	claims["app_id"] = app.Id
+// WithScopes adds the granted scopes as a space-separated "scope" claim.
+func WithScopes(scopes []string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimScope] = strings.Join(scopes, " ")
+	}
+}
+
+// WithIssuer names the service instance that issued the token.
+func WithIssuer(issuer string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimIssuer] = issuer
+	}
+}
+
+// WithPseudonymousID adds the user's pseudonymous analytics ID.
+func WithPseudonymousID(id string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimPseudonymousID] = id
+	}
+}
+
+// WithTenantID adds the tenant the user belongs to.
+func WithTenantID(tenantID string) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimTenantID] = tenantID
+	}
+}
+
+// WithVersion sets the token format version.
+func WithVersion(version int) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimVersion] = version
+	}
+}
+
+// WithAuthorizedParty records the app the token was exchanged from.
+func WithAuthorizedParty(appID int32) Option {
+	return func(claims jwt.MapClaims) {
+		claims[ClaimAuthorizedParty] = appID
+	}
+}
+
+// NewToken signs a token for user in app, issued at issuedAt and valid for duration.
+func NewToken(
+	user *models.User,
+	app *models.App,
+	issuedAt time.Time,
+	duration time.Duration,
+	opts ...Option,
+) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+
+	setClaims(token.Claims.(jwt.MapClaims), user, app, issuedAt, duration, opts)
 
 	tokenString, err := token.SignedString([]byte(app.Secret))
 	if err != nil {
@@ -23,3 +138,81 @@ func NewToken(user *models.User, app *models.App, duration time.Duration) (strin
 
 	return tokenString, nil
 }
+
+// ClaimNames returns the sorted names of the claims NewToken sets with opts.
+func ClaimNames(opts ...Option) []string {
+	claims := jwt.MapClaims{}
+
+	setClaims(claims, &models.User{}, &models.App{}, time.Time{}, 0, opts)
+
+	return slices.Sorted(maps.Keys(claims))
+}
+
+func setClaims(
+	claims jwt.MapClaims,
+	user *models.User,
+	app *models.App,
+	issuedAt time.Time,
+	duration time.Duration,
+	opts []Option,
+) {
+	claims[ClaimUserID] = user.Id
+	claims[ClaimEmail] = user.Name
+	claims[ClaimIssuedAt] = issuedAt.Unix()
+	claims[ClaimExpiresAt] = issuedAt.Add(duration).Unix()
+	claims[ClaimAppID] = app.Id
+
+	for _, opt := range opts {
+		opt(claims)
+	}
+}
+
+// ParseToken verifies the token signature with secret and returns its
+// claims. Time-based claims are not checked, that is left to the caller.
+func ParseToken(tokenString string, secret string) (map[string]any, error) {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// Issuer returns the "iss" claim of a token without verifying it, so the
+// verification key can be picked by issuer.
+func Issuer(tokenString string) (string, error) {
+	claims := jwt.MapClaims{}
+
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenString, claims); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	issuer, _ := claims[ClaimIssuer].(string)
+
+	return issuer, nil
+}
+
+// Fingerprint returns the client fingerprint the claims are bound to.
+func Fingerprint(claims map[string]any) (string, bool) {
+	cnf, ok := claims[ClaimConfirmation].(map[string]any)
+	if !ok {
+		return "", false
+	}
+
+	fingerprint, ok := cnf[confirmationFingerprint].(string)
+
+	return fingerprint, ok
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FailureCounter counts failures per key within a sliding window.
+type FailureCounter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	failures  map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewFailureCounter returns a FailureCounter that forgets failures older
+// than window.
+func NewFailureCounter(window time.Duration) *FailureCounter {
+	return &FailureCounter{
+		window:   window,
+		failures: make(map[string][]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Failures returns the number of failures for key within the window.
+func (c *FailureCounter) Failures(_ context.Context, key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.prune(key)), nil
+}
+
+// RecordFailure adds a failure for key.
+func (c *FailureCounter) RecordFailure(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	c.failures[key] = append(c.prune(key), now)
+
+	return nil
+}
+
+// ResetFailures forgets all failures for key.
+func (c *FailureCounter) ResetFailures(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.failures, key)
+
+	return nil
+}
+
+// prune drops failures of key that left the window and returns the rest.
+func (c *FailureCounter) prune(key string) []time.Time {
+	cutoff := c.now().Add(-c.window)
+
+	times := c.failures[key]
+
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+
+	times = times[i:]
+	if len(times) == 0 {
+		delete(c.failures, key)
+
+		return nil
+	}
+
+	c.failures[key] = times
+
+	return times
+}
+
+// sweep drops keys whose failures all left the window, at most once per
+// window duration.
+func (c *FailureCounter) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.window {
+		return
+	}
+
+	cutoff := now.Add(-c.window)
+
+	for key, times := range c.failures {
+		if !times[len(times)-1].After(cutoff) {
+			delete(c.failures, key)
+		}
+	}
+
+	c.lastSweep = now
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFailureCounter(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// failures are the times of recorded failures, from start.
+		failures []time.Duration
+		// at is when the failures are counted, from start.
+		at   time.Duration
+		want int
+	}{
+		{name: "none", at: time.Minute, want: 0},
+		{name: "within window", failures: []time.Duration{0, time.Minute}, at: 2 * time.Minute, want: 2},
+		{name: "oldest left window", failures: []time.Duration{0, 10 * time.Minute}, at: time.Hour, want: 1},
+		{name: "all left window", failures: []time.Duration{0, time.Minute}, at: 2 * time.Hour, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			c := NewFailureCounter(time.Hour)
+			c.now = func() time.Time { return now }
+
+			for _, offset := range tt.failures {
+				now = start.Add(offset)
+				_ = c.RecordFailure(context.Background(), "key")
+			}
+
+			now = start.Add(tt.at)
+
+			got, err := c.Failures(context.Background(), "key")
+			if err != nil {
+				t.Fatalf("Failures: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("Failures() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureCounterReset(t *testing.T) {
+	c := NewFailureCounter(time.Hour)
+	ctx := context.Background()
+
+	_ = c.RecordFailure(ctx, "a")
+	_ = c.RecordFailure(ctx, "b")
+	_ = c.ResetFailures(ctx, "a")
+
+	for key, want := range map[string]int{"a": 0, "b": 1} {
+		if got, _ := c.Failures(ctx, key); got != want {
+			t.Errorf("Failures(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestFailureCounterSweepsIdleKeys(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	c := NewFailureCounter(time.Hour)
+	c.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = c.RecordFailure(context.Background(), key)
+	}
+
+	now = start.Add(2 * time.Hour)
+	_ = c.RecordFailure(context.Background(), "d")
+
+	if got := len(c.failures); got != 1 {
+		t.Fatalf("tracked keys = %d, want 1", got)
+	}
+}
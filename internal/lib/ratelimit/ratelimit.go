@@ -0,0 +1,68 @@
+// Package ratelimit provides an in-memory fixed-window rate limiter.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	start time.Time
+	count int
+}
+
+// Limiter allows up to limit events per key within each window.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New returns a Limiter allowing limit events per key per window,
+// e.g. New(5, time.Hour) for five events an hour.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  window,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+func (l *Limiter) Allow(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	w, ok := l.buckets[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &bucket{start: now}
+		l.buckets[key] = w
+	}
+
+	w.count++
+
+	return w.count <= l.limit, nil
+}
+
+// sweep drops expired buckets, at most once per window duration.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	for key, w := range l.buckets {
+		if now.Sub(w.start) >= l.window {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// offsets are the times of the events for one key, from start.
+		offsets []time.Duration
+		want    []bool
+	}{
+		{
+			name:    "within limit",
+			offsets: []time.Duration{0, time.Minute},
+			want:    []bool{true, true},
+		},
+		{
+			name:    "over limit",
+			offsets: []time.Duration{0, time.Minute, 2 * time.Minute},
+			want:    []bool{true, true, false},
+		},
+		{
+			name:    "new window",
+			offsets: []time.Duration{0, time.Minute, 2 * time.Minute, time.Hour},
+			want:    []bool{true, true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := New(2, time.Hour)
+
+			for i, offset := range tt.offsets {
+				limiter.now = func() time.Time { return start.Add(offset) }
+
+				allowed, err := limiter.Allow(context.Background(), "key")
+				if err != nil {
+					t.Fatalf("Allow: %v", err)
+				}
+
+				if allowed != tt.want[i] {
+					t.Fatalf("event %d: Allow() = %v, want %v", i, allowed, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	limiter := New(1, time.Hour)
+
+	for _, key := range []string{"a", "b"} {
+		if allowed, _ := limiter.Allow(context.Background(), key); !allowed {
+			t.Fatalf("Allow(%q) = false, want true", key)
+		}
+	}
+
+	if allowed, _ := limiter.Allow(context.Background(), "a"); allowed {
+		t.Fatal("second Allow(a) = true, want false")
+	}
+}
+
+func TestLimiterSweepsExpiredBuckets(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	limiter := New(1, time.Hour)
+	limiter.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = limiter.Allow(context.Background(), key)
+	}
+
+	now = start.Add(2 * time.Hour)
+	_, _ = limiter.Allow(context.Background(), "d")
+
+	if got := len(limiter.buckets); got != 1 {
+		t.Fatalf("buckets = %d, want 1", got)
+	}
+}
@@ -0,0 +1,40 @@
+// Package totp builds provisioning URIs for TOTP authenticator apps.
+package totp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Parameters of the generated codes. Authenticator apps assume these when
+// the URI omits them, but not all apps default the same way.
+const (
+	Algorithm = "SHA1"
+	Digits    = 6
+	Period    = 30
+)
+
+// ProvisioningURI returns the otpauth://totp URI for a base32 secret, to be
+// rendered as a QR code. The label is "issuer:account".
+func ProvisioningURI(secret, issuer, account string) string {
+	label := escape(account)
+	if issuer != "" {
+		label = escape(issuer) + ":" + label
+	}
+
+	query := "secret=" + escape(strings.TrimRight(strings.ToUpper(secret), "="))
+	if issuer != "" {
+		query += "&issuer=" + escape(issuer)
+	}
+
+	query += fmt.Sprintf("&algorithm=%s&digits=%d&period=%d", Algorithm, Digits, Period)
+
+	return "otpauth://totp/" + label + "?" + query
+}
+
+// escape percent-encodes s, using %20 for spaces as authenticator apps
+// expect rather than the "+" of form encoding.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
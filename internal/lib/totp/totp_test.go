@@ -0,0 +1,55 @@
+package totp
+
+import "testing"
+
+func TestProvisioningURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		issuer  string
+		account string
+		want    string
+	}{
+		{
+			name:    "issuer and account",
+			secret:  "JBSWY3DPEHPK3PXP",
+			issuer:  "Example",
+			account: "alice@example.com",
+			want:    "otpauth://totp/Example:alice%40example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example&algorithm=SHA1&digits=6&period=30",
+		},
+		{
+			name:    "without issuer",
+			secret:  "JBSWY3DPEHPK3PXP",
+			account: "alice",
+			want:    "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=SHA1&digits=6&period=30",
+		},
+		{
+			name:    "spaces are percent-encoded",
+			secret:  "JBSWY3DPEHPK3PXP",
+			issuer:  "Acme Corp",
+			account: "alice smith",
+			want:    "otpauth://totp/Acme%20Corp:alice%20smith?secret=JBSWY3DPEHPK3PXP&issuer=Acme%20Corp&algorithm=SHA1&digits=6&period=30",
+		},
+		{
+			name:    "secret is normalized",
+			secret:  "jbswy3dpehpk3pxp====",
+			account: "alice",
+			want:    "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=SHA1&digits=6&period=30",
+		},
+		{
+			name:    "colon in issuer is escaped",
+			secret:  "JBSWY3DPEHPK3PXP",
+			issuer:  "A:B",
+			account: "alice",
+			want:    "otpauth://totp/A%3AB:alice?secret=JBSWY3DPEHPK3PXP&issuer=A%3AB&algorithm=SHA1&digits=6&period=30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProvisioningURI(tt.secret, tt.issuer, tt.account); got != tt.want {
+				t.Fatalf("ProvisioningURI() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrInvalidActionToken = errors.New("invalid action token")
+	ErrActionTokenExpired = errors.New("action token expired")
+	ErrActionTokenUsed    = errors.New("action token already used")
+)
+
+// ActionTokenStore keeps single-use action tokens by the hash of the token.
+type ActionTokenStore interface {
+	SaveActionToken(
+		ctx context.Context,
+		token models.ActionToken,
+	) error
+	ActionToken(
+		ctx context.Context,
+		hash []byte,
+	) (*models.ActionToken, error)
+	// MarkActionTokenUsed must atomically mark the token used and return
+	// storage.ErrActionTokenUsed if it already was.
+	MarkActionTokenUsed(
+		ctx context.Context,
+		hash []byte,
+		usedAt time.Time,
+	) error
+}
+
+// WithActionTokens enables single-use action tokens backed by store.
+func WithActionTokens(store ActionTokenStore) Option {
+	return func(auth *Auth) {
+		auth.actionTokenStore = store
+	}
+}
+
+// IssueActionToken returns a token that allows userID to perform action on
+// resourceID once within ttl. Only a hash of the token is stored.
+func (auth *Auth) IssueActionToken(
+	ctx context.Context,
+	userID int64,
+	action string,
+	resourceID string,
+	ttl time.Duration,
+) (string, error) {
+	const op = "auth.IssueActionToken"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+		slog.String("action", action),
+	)
+
+	if auth.actionTokenStore == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	token, err := auth.newOpaqueToken()
+	if err != nil {
+		log.Error("failed to generate action token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = auth.actionTokenStore.SaveActionToken(ctx, models.ActionToken{
+		Hash:       hashToken(token),
+		UserId:     userID,
+		Action:     action,
+		ResourceId: resourceID,
+		ExpiresAt:  auth.now().Add(ttl),
+	})
+	if err != nil {
+		log.Error("failed to save action token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// ConsumeActionToken checks that token was issued for action on resourceID
+// and marks it used. It returns the user the token was issued to.
+// A token for a different action or resource is rejected and stays unused.
+func (auth *Auth) ConsumeActionToken(
+	ctx context.Context,
+	token string,
+	action string,
+	resourceID string,
+) (int64, error) {
+	const op = "auth.ConsumeActionToken"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("action", action),
+	)
+
+	if auth.actionTokenStore == nil {
+		return 0, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	hash := hashToken(token)
+
+	stored, err := auth.actionTokenStore.ActionToken(ctx, hash)
+	if err != nil {
+		if errors.Is(err, storage.ErrActionTokenNotFound) {
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidActionToken)
+		}
+
+		log.Error("failed to get action token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if stored.Action != action || stored.ResourceId != resourceID {
+		log.Warn("action token used for another action or resource")
+
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidActionToken)
+	}
+
+	now := auth.now()
+
+	if !stored.UsedAt.IsZero() {
+		return 0, fmt.Errorf("%s: %w", op, ErrActionTokenUsed)
+	}
+
+	if !now.Before(stored.ExpiresAt) {
+		return 0, fmt.Errorf("%s: %w", op, ErrActionTokenExpired)
+	}
+
+	if err := auth.actionTokenStore.MarkActionTokenUsed(ctx, hash, now); err != nil {
+		if errors.Is(err, storage.ErrActionTokenUsed) {
+			return 0, fmt.Errorf("%s: %w", op, ErrActionTokenUsed)
+		}
+
+		log.Error("failed to mark action token used", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stored.UserId, nil
+}
+
+// newOpaqueToken returns a random URL-safe token.
+func (auth *Auth) newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(auth.random, b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+
+	return hash[:]
+}
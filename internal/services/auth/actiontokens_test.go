@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConsumeActionToken(t *testing.T) {
+	const (
+		userID   int64 = 7
+		action         = "delete_repo"
+		resource       = "repo-1"
+	)
+
+	tests := []struct {
+		name     string
+		action   string
+		resource string
+		// advance moves the clock before consuming.
+		advance time.Duration
+		// consumeTwice consumes the token once before the checked call.
+		consumeTwice bool
+		wantErr      error
+	}{
+		{name: "valid", action: action, resource: resource},
+		{name: "other action", action: "rename_repo", resource: resource, wantErr: ErrInvalidActionToken},
+		{name: "other resource", action: action, resource: "repo-2", wantErr: ErrInvalidActionToken},
+		{name: "expired", action: action, resource: resource, advance: time.Minute, wantErr: ErrActionTokenExpired},
+		{name: "already used", action: action, resource: resource, consumeTwice: true, wantErr: ErrActionTokenUsed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithActionTokens(newFakeActionTokens()))
+			ctx := context.Background()
+
+			token, err := f.auth.IssueActionToken(ctx, userID, action, resource, time.Minute)
+			if err != nil {
+				t.Fatalf("IssueActionToken: %v", err)
+			}
+
+			if tt.consumeTwice {
+				if _, err := f.auth.ConsumeActionToken(ctx, token, action, resource); err != nil {
+					t.Fatalf("first ConsumeActionToken: %v", err)
+				}
+			}
+
+			f.clock.Advance(tt.advance)
+
+			got, err := f.auth.ConsumeActionToken(ctx, token, tt.action, tt.resource)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConsumeActionToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && got != userID {
+				t.Fatalf("ConsumeActionToken() = %d, want %d", got, userID)
+			}
+		})
+	}
+}
+
+func TestConsumeActionTokenMismatchKeepsTokenUnused(t *testing.T) {
+	f := newFixture(t, WithActionTokens(newFakeActionTokens()))
+	ctx := context.Background()
+
+	token, err := f.auth.IssueActionToken(ctx, 7, "delete_repo", "repo-1", time.Minute)
+	if err != nil {
+		t.Fatalf("IssueActionToken: %v", err)
+	}
+
+	if _, err := f.auth.ConsumeActionToken(ctx, token, "delete_repo", "repo-2"); !errors.Is(err, ErrInvalidActionToken) {
+		t.Fatalf("ConsumeActionToken() error = %v, want %v", err, ErrInvalidActionToken)
+	}
+
+	if _, err := f.auth.ConsumeActionToken(ctx, token, "delete_repo", "repo-1"); err != nil {
+		t.Fatalf("ConsumeActionToken() after mismatch: %v", err)
+	}
+}
+
+func TestConsumeUnknownActionToken(t *testing.T) {
+	f := newFixture(t, WithActionTokens(newFakeActionTokens()))
+
+	if _, err := f.auth.ConsumeActionToken(context.Background(), "unknown", "a", "r"); !errors.Is(err, ErrInvalidActionToken) {
+		t.Fatalf("ConsumeActionToken() error = %v, want %v", err, ErrInvalidActionToken)
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ListAdmins returns every admin user with password hashes stripped.
+// The caller, taken from clientinfo.UserID, must be an admin.
+func (auth *Auth) ListAdmins(ctx context.Context) ([]*models.User, error) {
+	const op = "auth.ListAdmins"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	admins, err := auth.userProvider.Admins(ctx)
+	if err != nil {
+		log.Error("failed to list admins", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, admin := range admins {
+		redacted := *admin
+		redacted.PassHash = nil
+		admins[i] = &redacted
+	}
+
+	return admins, nil
+}
+
+// requireAdmin returns ErrPermissionDenied unless the authenticated caller
+// in ctx is an admin.
+func (auth *Auth) requireAdmin(ctx context.Context) error {
+	callerID, ok := clientinfo.UserID(ctx)
+	if !ok {
+		return ErrPermissionDenied
+	}
+
+	isAdmin, err := auth.userProvider.IsAdmin(ctx, callerID)
+	if err != nil {
+		return err
+	}
+
+	if !isAdmin {
+		auth.log.Warn("admin permission denied", slog.Int64("userID", callerID))
+
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// requireSelfOrAdmin returns ErrPermissionDenied unless the authenticated
+// caller in ctx is userID or an admin.
+func (auth *Auth) requireSelfOrAdmin(ctx context.Context, userID int64) error {
+	if callerID, ok := clientinfo.UserID(ctx); ok && callerID == userID {
+		return nil
+	}
+
+	return auth.requireAdmin(ctx)
+}
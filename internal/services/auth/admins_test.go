@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/clientinfo"
+	"testing"
+)
+
+func TestListAdmins(t *testing.T) {
+	tests := []struct {
+		name       string
+		caller     string
+		wantErr    error
+		wantAdmins []string
+	}{
+		{name: "admin", caller: "admin", wantAdmins: []string{"admin@example.com", "second@example.com"}},
+		{name: "not admin", caller: "user", wantErr: ErrPermissionDenied},
+		{name: "anonymous", caller: "anonymous", wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			adminCtx := f.addAdmin(t)
+
+			second := f.addUser(t, "second@example.com", testPassword)
+			f.users.admins[int64(second.Id)] = true
+
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			ctx := map[string]context.Context{
+				"admin":     adminCtx,
+				"user":      clientinfo.WithUserID(context.Background(), int64(user.Id)),
+				"anonymous": context.Background(),
+			}[tt.caller]
+
+			admins, err := f.auth.ListAdmins(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListAdmins() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(admins) != len(tt.wantAdmins) {
+				t.Fatalf("ListAdmins() returned %d admins, want %d", len(admins), len(tt.wantAdmins))
+			}
+
+			for i, admin := range admins {
+				if admin.Name != tt.wantAdmins[i] {
+					t.Errorf("admin %d = %s, want %s", i, admin.Name, tt.wantAdmins[i])
+				}
+
+				if admin.PassHash != nil {
+					t.Errorf("admin %s has a password hash", admin.Name)
+				}
+
+				if stored := f.users.get(int64(admin.Id)); len(stored.PassHash) == 0 {
+					t.Errorf("stored hash of %s was cleared", admin.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// touchTimeout bounds the background update of an API key's last use.
+const touchTimeout = 5 * time.Second
+
+// APIKeyInfo describes an API key without its secret.
+type APIKeyInfo struct {
+	ID         string
+	UserID     int64
+	Label      string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// APIKeyProvider stores API keys. Implementations must scope calls taking a
+// userID to that user, so a user can only see and revoke their own keys.
+// Revoked keys must no longer be returned by APIKey.
+type APIKeyProvider interface {
+	APIKey(
+		ctx context.Context,
+		keyID string,
+	) (*models.APIKey, error)
+	APIKeys(
+		ctx context.Context,
+		userID int64,
+	) ([]models.APIKey, error)
+	RevokeAPIKey(
+		ctx context.Context,
+		userID int64,
+		keyID string,
+	) error
+	TouchAPIKey(
+		ctx context.Context,
+		keyID string,
+		usedAt time.Time,
+	) error
+	// APIKeysUnusedSince returns keys last used before the given time.
+	// Keys that were never used are compared by their creation time.
+	APIKeysUnusedSince(
+		ctx context.Context,
+		before time.Time,
+	) ([]models.APIKey, error)
+}
+
+// WithAPIKeys enables API key management backed by provider.
+func WithAPIKeys(provider APIKeyProvider) Option {
+	return func(auth *Auth) {
+		auth.apiKeyProvider = provider
+	}
+}
+
+// ListAPIKeys returns the API keys of the user. Secrets are never returned.
+// The caller must be the user or an admin.
+func (auth *Auth) ListAPIKeys(ctx context.Context, userID int64) ([]APIKeyInfo, error) {
+	const op = "auth.ListAPIKeys"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	if auth.apiKeyProvider == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireSelfOrAdmin(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := auth.apiKeyProvider.APIKeys(ctx, userID)
+	if err != nil {
+		log.Error("failed to list api keys", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	infos := make([]APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, apiKeyInfo(key))
+	}
+
+	return infos, nil
+}
+
+// RevokeAPIKey revokes one of the user's API keys. The caller must be the
+// user or an admin.
+func (auth *Auth) RevokeAPIKey(ctx context.Context, userID int64, keyID string) error {
+	const op = "auth.RevokeAPIKey"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+		slog.String("keyID", keyID),
+	)
+
+	if auth.apiKeyProvider == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireSelfOrAdmin(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.apiKeyProvider.RevokeAPIKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key not found", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+
+		log.Error("failed to revoke api key", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key revoked")
+
+	return nil
+}
+
+// AuthenticateAPIKey resolves an API key of the form "<keyID>.<secret>" to
+// the user owning it. The key's last use is recorded in the background.
+func (auth *Auth) AuthenticateAPIKey(ctx context.Context, apiKey string) (int64, error) {
+	const op = "auth.AuthenticateAPIKey"
+
+	if auth.apiKeyProvider == nil {
+		return 0, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	keyID, secret, ok := strings.Cut(apiKey, ".")
+	if !ok || keyID == "" || secret == "" {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidAPIKey)
+	}
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("keyID", keyID),
+	)
+
+	key, err := auth.apiKeyProvider.APIKey(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key not found", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidAPIKey)
+		}
+
+		log.Error("failed to get api key", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if subtle.ConstantTimeCompare(hashToken(secret), key.SecretHash) != 1 {
+		log.Warn("api key secret mismatch")
+
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidAPIKey)
+	}
+
+	go auth.touchAPIKey(context.WithoutCancel(ctx), key.Id, auth.now())
+
+	return key.UserId, nil
+}
+
+// StaleAPIKeys reports API keys that have not been used for unusedFor.
+// The caller must be an admin.
+func (auth *Auth) StaleAPIKeys(ctx context.Context, unusedFor time.Duration) ([]APIKeyInfo, error) {
+	const op = "auth.StaleAPIKeys"
+
+	if auth.apiKeyProvider == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := auth.apiKeyProvider.APIKeysUnusedSince(ctx, auth.now().Add(-unusedFor))
+	if err != nil {
+		auth.log.Error("failed to list stale api keys",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	infos := make([]APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, apiKeyInfo(key))
+	}
+
+	return infos, nil
+}
+
+// touchAPIKey records the key's last use. It is best-effort: failures are
+// only logged so authentication never waits on it.
+func (auth *Auth) touchAPIKey(ctx context.Context, keyID string, usedAt time.Time) {
+	const op = "auth.touchAPIKey"
+
+	ctx, cancel := context.WithTimeout(ctx, touchTimeout)
+	defer cancel()
+
+	if err := auth.apiKeyProvider.TouchAPIKey(ctx, keyID, usedAt); err != nil {
+		auth.log.Warn("failed to record api key use",
+			slog.String("op", op),
+			slog.String("keyID", keyID),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+	}
+}
+
+func apiKeyInfo(key models.APIKey) APIKeyInfo {
+	return APIKeyInfo{
+		ID:         key.Id,
+		UserID:     key.UserId,
+		Label:      key.Label,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
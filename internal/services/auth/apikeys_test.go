@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+// apiKeyFixture has an owner with two API keys, another user and an admin.
+type apiKeyFixture struct {
+	*fixture
+	keys    *fakeAPIKeys
+	owner   int64
+	callers map[string]context.Context
+}
+
+func newAPIKeyFixture(t *testing.T) *apiKeyFixture {
+	t.Helper()
+
+	keys := newFakeAPIKeys()
+	f := newFixture(t, WithAPIKeys(keys))
+
+	owner := int64(f.addUser(t, "owner@example.com", testPassword).Id)
+	other := int64(f.addUser(t, "other@example.com", testPassword).Id)
+	adminCtx := f.addAdmin(t)
+
+	keys.keys["k1"] = &models.APIKey{Id: "k1", UserId: owner, Label: "ci", SecretHash: hashToken("s1"), CreatedAt: testEpoch}
+	keys.keys["k2"] = &models.APIKey{Id: "k2", UserId: owner, Label: "deploy", SecretHash: hashToken("s2"), CreatedAt: testEpoch}
+	keys.keys["k3"] = &models.APIKey{Id: "k3", UserId: other, Label: "other", SecretHash: hashToken("s3"), CreatedAt: testEpoch}
+
+	return &apiKeyFixture{
+		fixture: f,
+		keys:    keys,
+		owner:   owner,
+		callers: map[string]context.Context{
+			"owner":     clientinfo.WithUserID(context.Background(), owner),
+			"other":     clientinfo.WithUserID(context.Background(), other),
+			"admin":     adminCtx,
+			"anonymous": context.Background(),
+		},
+	}
+}
+
+func TestListAPIKeys(t *testing.T) {
+	tests := []struct {
+		caller   string
+		wantErr  error
+		wantKeys []string
+	}{
+		{caller: "owner", wantKeys: []string{"k1", "k2"}},
+		{caller: "admin", wantKeys: []string{"k1", "k2"}},
+		{caller: "other", wantErr: ErrPermissionDenied},
+		{caller: "anonymous", wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.caller, func(t *testing.T) {
+			f := newAPIKeyFixture(t)
+
+			infos, err := f.auth.ListAPIKeys(f.callers[tt.caller], f.owner)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListAPIKeys() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var ids []string
+			for _, info := range infos {
+				ids = append(ids, info.ID)
+
+				if info.UserID != f.owner {
+					t.Errorf("key %s has UserID %d, want %d", info.ID, info.UserID, f.owner)
+				}
+			}
+
+			if !slices.Equal(ids, tt.wantKeys) {
+				t.Fatalf("ListAPIKeys() = %v, want %v", ids, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		keyID   string
+		wantErr error
+	}{
+		{name: "by owner", caller: "owner", keyID: "k1"},
+		{name: "by admin", caller: "admin", keyID: "k1"},
+		{name: "by other user", caller: "other", keyID: "k1", wantErr: ErrPermissionDenied},
+		{name: "anonymous", caller: "anonymous", keyID: "k1", wantErr: ErrPermissionDenied},
+		{name: "unknown key", caller: "owner", keyID: "missing", wantErr: ErrAPIKeyNotFound},
+		{name: "key of another user", caller: "owner", keyID: "k3", wantErr: ErrAPIKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAPIKeyFixture(t)
+
+			err := f.auth.RevokeAPIKey(f.callers[tt.caller], f.owner, tt.keyID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevokeAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, authErr := f.auth.AuthenticateAPIKey(context.Background(), "k1.s1")
+
+			revoked := errors.Is(authErr, ErrInvalidAPIKey)
+			if wantRevoked := tt.wantErr == nil; revoked != wantRevoked {
+				t.Fatalf("k1 revoked = %v, want %v", revoked, wantRevoked)
+			}
+		})
+	}
+}
+
+func TestAPIKeysNotConfigured(t *testing.T) {
+	f := newFixture(t)
+	ctx := f.addAdmin(t)
+
+	if _, err := f.auth.ListAPIKeys(ctx, 1); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("ListAPIKeys() error = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if err := f.auth.RevokeAPIKey(ctx, 1, "k1"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("RevokeAPIKey() error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestAuthenticateAPIKeyRecordsLastUse(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantUserID bool
+		wantErr    error
+	}{
+		{name: "valid", apiKey: "k1.s1", wantUserID: true},
+		{name: "wrong secret", apiKey: "k1.s2", wantErr: ErrInvalidAPIKey},
+		{name: "unknown key", apiKey: "missing.s1", wantErr: ErrInvalidAPIKey},
+		{name: "malformed", apiKey: "k1", wantErr: ErrInvalidAPIKey},
+		{name: "empty secret", apiKey: "k1.", wantErr: ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAPIKeyFixture(t)
+			f.keys.touched = make(chan string, 1)
+
+			userID, err := f.auth.AuthenticateAPIKey(context.Background(), tt.apiKey)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !tt.wantUserID {
+				return
+			}
+
+			if userID != f.owner {
+				t.Fatalf("AuthenticateAPIKey() = %d, want %d", userID, f.owner)
+			}
+
+			if keyID := <-f.keys.touched; keyID != "k1" {
+				t.Fatalf("touched %q, want k1", keyID)
+			}
+
+			if got := f.keys.get("k1").LastUsedAt; !got.Equal(testEpoch) {
+				t.Fatalf("LastUsedAt = %v, want %v", got, testEpoch)
+			}
+		})
+	}
+}
+
+func TestStaleAPIKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   string
+		wantErr  error
+		wantKeys []string
+	}{
+		{name: "admin", caller: "admin", wantKeys: []string{"k2", "k3"}},
+		{name: "not admin", caller: "owner", wantErr: ErrPermissionDenied},
+		{name: "anonymous", caller: "anonymous", wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAPIKeyFixture(t)
+
+			// k1 was used recently, the others were never used since
+			// being created at testEpoch.
+			f.clock.Advance(48 * time.Hour)
+			f.keys.keys["k1"].LastUsedAt = f.clock.Now().Add(-time.Hour)
+
+			infos, err := f.auth.StaleAPIKeys(f.callers[tt.caller], 24*time.Hour)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StaleAPIKeys() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var ids []string
+			for _, info := range infos {
+				ids = append(ids, info.ID)
+			}
+
+			if !slices.Equal(ids, tt.wantKeys) {
+				t.Fatalf("StaleAPIKeys() = %v, want %v", ids, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestVerifyAppSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		anonymous bool
+		appID     int32
+		candidate string
+		want      bool
+		wantErr   error
+	}{
+		{name: "matching", appID: testAppID, candidate: testAppSecret, want: true},
+		{name: "not matching", appID: testAppID, candidate: "guess"},
+		{name: "longer candidate", appID: testAppID, candidate: testAppSecret + "x"},
+		{name: "unknown app", appID: 42, candidate: testAppSecret, wantErr: ErrInvalidAppID},
+		{name: "not admin", anonymous: true, appID: testAppID, candidate: testAppSecret, wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx := f.addAdmin(t)
+
+			if tt.anonymous {
+				user := f.addUser(t, "user@example.com", testPassword)
+				ctx = clientinfo.WithUserID(context.Background(), int64(user.Id))
+			}
+
+			got, err := f.auth.VerifyAppSecret(ctx, tt.appID, tt.candidate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAppSecret() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("VerifyAppSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAppTokensValidAfter(t *testing.T) {
+	const otherAppID int32 = 2
+
+	f := newFixture(t)
+	WithAppUpdater(f.apps)(f.auth)
+	f.apps.add(models.App{Id: otherAppID, Name: "other", Secret: "other-secret"})
+	f.addUser(t, "user@example.com", testPassword)
+	adminCtx := f.addAdmin(t)
+
+	before := f.login(t, "user@example.com")
+
+	other, err := f.auth.Login(context.Background(), "user@example.com", testPassword, otherAppID)
+	if err != nil {
+		t.Fatalf("Login(other app): %v", err)
+	}
+
+	f.clock.Advance(time.Minute)
+
+	if err := f.auth.SetAppTokensValidAfter(adminCtx, testAppID, f.clock.Now()); err != nil {
+		t.Fatalf("SetAppTokensValidAfter: %v", err)
+	}
+
+	after := f.login(t, "user@example.com")
+
+	tests := []struct {
+		name    string
+		token   string
+		appID   int32
+		wantErr error
+	}{
+		{name: "issued before", token: before.Token, appID: testAppID, wantErr: ErrTokenRevoked},
+		{name: "issued at the cutoff", token: after.Token, appID: testAppID},
+		{name: "other app", token: other.Token, appID: otherAppID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.auth.ValidateToken(context.Background(), tt.token, tt.appID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetAppTokensValidAfterRejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		unconfigured bool
+		anonymous    bool
+		appID        int32
+		wantErr      error
+	}{
+		{name: "not configured", unconfigured: true, appID: testAppID, wantErr: ErrNotConfigured},
+		{name: "not admin", anonymous: true, appID: testAppID, wantErr: ErrPermissionDenied},
+		{name: "unknown app", appID: 42, wantErr: ErrInvalidAppID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if !tt.unconfigured {
+				WithAppUpdater(f.apps)(f.auth)
+			}
+
+			ctx := f.addAdmin(t)
+			if tt.anonymous {
+				ctx = context.Background()
+			}
+
+			if err := f.auth.SetAppTokensValidAfter(ctx, tt.appID, testEpoch); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetAppTokensValidAfter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
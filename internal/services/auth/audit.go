@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"strconv"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Audit event types.
+const (
+	AuditUserRegistered = "user_registered"
+	AuditLoginSucceeded = "login_succeeded"
+	AuditLoginFailed    = "login_failed"
+	AuditBulkOperation  = "bulk_operation"
+)
+
+// Page sizes of the audit and event logs.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// AuditStore is an append-only log of audit events.
+type AuditStore interface {
+	// AppendAuditEvent stores event under the next sequence number.
+	AppendAuditEvent(ctx context.Context, event models.AuditEvent) error
+	// AuditEvents returns up to limit events with a sequence number above
+	// afterSeq, in ascending order.
+	AuditEvents(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error)
+}
+
+// WithAuditStore records audit events in store.
+func WithAuditStore(store AuditStore) Option {
+	return func(auth *Auth) {
+		auth.auditStore = store
+	}
+}
+
+// StreamAuditEvents returns the events after sinceCursor, oldest first, and
+// the cursor to pass to the next call. An empty cursor starts from the
+// beginning. When no new events exist, the same cursor is returned, so
+// consumers can keep polling without gaps or duplicates. Events carry
+// emails and IPs, so the caller must be an admin.
+func (auth *Auth) StreamAuditEvents(
+	ctx context.Context,
+	sinceCursor string,
+	limit int,
+) ([]models.AuditEvent, string, error) {
+	const op = "auth.StreamAuditEvents"
+
+	if auth.auditStore == nil {
+		return nil, "", fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	afterSeq, err := decodeCursor(sinceCursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := auth.auditStore.AuditEvents(ctx, afterSeq, pageLimit(limit))
+	if err != nil {
+		auth.log.Error("failed to read audit events",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(events) > 0 {
+		afterSeq = events[len(events)-1].Seq
+	}
+
+	return events, encodeCursor(afterSeq), nil
+}
+
+// audit appends an event when an audit store is configured. Failures are
+// logged and don't fail the audited operation.
+func (auth *Auth) audit(ctx context.Context, event models.AuditEvent) {
+	if auth.auditStore == nil {
+		return
+	}
+
+	event.OccurredAt = auth.now()
+	event.IP, _ = clientinfo.IP(ctx)
+
+	if err := auth.auditStore.AppendAuditEvent(ctx, event); err != nil {
+		auth.log.Error("failed to append audit event",
+			slog.String("type", event.Type),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+	}
+}
+
+// pageLimit returns the page size for a requested limit: the default if
+// limit is not positive, and at most maxPageSize.
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+
+	return min(limit, maxPageSize)
+}
+
+func encodeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(seq, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	seq, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return seq, nil
+}
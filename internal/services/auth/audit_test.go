@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+)
+
+func TestStreamAuditEvents(t *testing.T) {
+	store := &fakeAudit{}
+	f := newFixture(t, WithAuditStore(store))
+	adminCtx := f.addAdmin(t)
+
+	for range 5 {
+		f.auth.audit(context.Background(), models.AuditEvent{Type: AuditLoginSucceeded})
+	}
+
+	var (
+		cursor string
+		seqs   []int64
+	)
+
+	for page := 0; ; page++ {
+		events, next, err := f.auth.StreamAuditEvents(adminCtx, cursor, 2)
+		if err != nil {
+			t.Fatalf("StreamAuditEvents: %v", err)
+		}
+
+		if len(events) == 0 {
+			if next != cursor {
+				t.Fatalf("cursor moved on an empty page: %q -> %q", cursor, next)
+			}
+
+			break
+		}
+
+		if len(events) > 2 {
+			t.Fatalf("page %d has %d events, want at most 2", page, len(events))
+		}
+
+		for _, event := range events {
+			seqs = append(seqs, event.Seq)
+		}
+
+		cursor = next
+	}
+
+	if want := []int64{1, 2, 3, 4, 5}; !slices.Equal(seqs, want) {
+		t.Fatalf("streamed %v, want %v", seqs, want)
+	}
+
+	f.auth.audit(context.Background(), models.AuditEvent{Type: AuditLoginFailed})
+
+	events, _, err := f.auth.StreamAuditEvents(adminCtx, cursor, 2)
+	if err != nil {
+		t.Fatalf("StreamAuditEvents: %v", err)
+	}
+
+	if len(events) != 1 || events[0].Seq != 6 {
+		t.Fatalf("events after polling = %+v, want only seq 6", events)
+	}
+}
+
+func TestStreamAuditEventsCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		wantErr error
+	}{
+		{name: "start", cursor: ""},
+		{name: "valid", cursor: encodeCursor(3)},
+		{name: "not base64", cursor: "!!", wantErr: ErrInvalidCursor},
+		{name: "not a number", cursor: "YWJj", wantErr: ErrInvalidCursor},
+		{name: "negative", cursor: encodeCursor(-1), wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithAuditStore(&fakeAudit{}))
+
+			if _, _, err := f.auth.StreamAuditEvents(f.addAdmin(t), tt.cursor, 10); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StreamAuditEvents() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamAuditEventsRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller string
+	}{
+		{name: "not admin", caller: "user"},
+		{name: "anonymous", caller: "anonymous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithAuditStore(&fakeAudit{}))
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			ctx := context.Background()
+			if tt.caller == "user" {
+				ctx = clientinfo.WithUserID(ctx, int64(user.Id))
+			}
+
+			if _, _, err := f.auth.StreamAuditEvents(ctx, "", 10); !errors.Is(err, ErrPermissionDenied) {
+				t.Fatalf("StreamAuditEvents() error = %v, want %v", err, ErrPermissionDenied)
+			}
+		})
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: defaultPageSize},
+		{limit: -5, want: defaultPageSize},
+		{limit: 10, want: 10},
+		{limit: maxPageSize + 1, want: maxPageSize},
+	}
+
+	for _, tt := range tests {
+		if got := pageLimit(tt.limit); got != tt.want {
+			t.Errorf("pageLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestLoginIsAudited(t *testing.T) {
+	store := &fakeAudit{}
+	f := newFixture(t, WithAuditStore(store))
+	f.addUser(t, "user@example.com", testPassword)
+
+	ctx := clientinfo.WithIP(context.Background(), "10.0.0.1")
+
+	_, _ = f.auth.Login(ctx, "user@example.com", "wrong", testAppID)
+	_, _ = f.auth.Login(ctx, "missing@example.com", testPassword, testAppID)
+
+	if _, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	want := []string{AuditLoginFailed, AuditLoginFailed, AuditLoginSucceeded}
+	if got := store.types(); !slices.Equal(got, want) {
+		t.Fatalf("audited %v, want %v", got, want)
+	}
+
+	for _, event := range store.events {
+		if event.IP != "10.0.0.1" || !event.OccurredAt.Equal(testEpoch) {
+			t.Errorf("event %+v lacks the client IP or time", event)
+		}
+	}
+}
@@ -2,22 +2,115 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"io"
 	"log/slog"
 	"sso/internal/domain/models"
 	jwt "sso/internal/lib"
+	"sso/internal/lib/bloom"
+	"sso/internal/lib/clientinfo"
 	"sso/internal/storage"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log               *slog.Logger
+	userSaver         UserSaver
+	userProvider      UserProvider
+	userReplica       UserProvider
+	userUpdater       UserUpdater
+	userPurger        UserPurger
+	userDeleter       UserDeleter
+	appProvider       AppProvider
+	appUpdater        AppUpdater
+	tokenTTL          time.Duration
+	hasher            PasswordHasher
+	now               func() time.Time
+	random            io.Reader
+	env               string
+	testMode          bool
+	metrics           Metrics
+	masterKey         []byte
+	previousMasterKey []byte
+	csrfKey           []byte
+
+	clockRollback       clockRollback
+	passwordPolicy      PasswordPolicy
+	commonPasswords     *CommonPasswordChecker
+	captcha             *captchaConfig
+	auditStore          AuditStore
+	accessTTLBounds     TTLBounds
+	refreshTTLBounds    TTLBounds
+	ipBlocklist         IPBlocklist
+	rotationStore       RotationStore
+	invitationStore     InvitationStore
+	invitationTTL       time.Duration
+	rolesClaim          bool
+	registrationLimiter RateLimiter
+	apiKeyProvider      APIKeyProvider
+	actionTokenStore    ActionTokenStore
+	sessionStore        SessionStore
+	sessionTimeouts     sessionTimeouts
+	sessionGauges       sessionGauges
+	selfTestAppID       int32
+	tokenCookie         CookieConfig
+	domainCheck         *DomainCheck
+	verification        *verificationConfig
+	hashSlots           chan struct{}
+	hashScanner         PasswordHashScanner
+	idempotencyStore    IdempotencyStore
+	idempotencyWindow   time.Duration
+	evictionPolicies    map[string]EvictionPolicy
+	validationCacheTTL  time.Duration
+	eventStore          EventStore
+	peppers             peppers
+	pepperStore         PepperStore
+	revokedTokens       RevokedTokenStore
+	revocationFPRate    float64
+	revocationFilter    atomic.Pointer[bloom.Filter]
+	minTokenVersion     int
+	issuer              string
+	trustedIssuers      map[string]string
+	analyticsKey        []byte
+	pseudonymClaim      bool
+}
+
+// Option configures optional behaviour of the Auth Service.
+type Option func(auth *Auth)
+
+// LoginOption customizes a single Login call.
+type LoginOption func(options *loginOptions)
+
+type loginOptions struct {
+	resources      []string
+	captchaToken   string
+	idempotencyKey string
+}
+
+// LoginForResources restricts the token to the given resource indicators
+// (RFC 8707), see RequireResource.
+func LoginForResources(resources ...string) LoginOption {
+	return func(options *loginOptions) {
+		options.resources = append(options.resources, resources...)
+	}
+}
+
+// LoginResult is returned by a successful login.
+type LoginResult struct {
+	Token            string
+	ExpiresAt        time.Time
+	SessionID        string
+	SessionExpiresAt time.Time
+	// TokenID is the "jti" of Token, see RevokeToken.
+	TokenID string
+	// CSRFToken is empty unless a CSRF key is configured, see WithCSRFKey.
+	CSRFToken string
 }
 
 type UserSaver interface {
@@ -33,10 +126,56 @@ type UserProvider interface {
 		ctx context.Context,
 		email string,
 	) (*models.User, error)
+	UserByID(
+		ctx context.Context,
+		userID int64,
+	) (*models.User, error)
 	IsAdmin(
 		ctx context.Context,
 		userID int64,
 	) (bool, error)
+	Admins(ctx context.Context) ([]*models.User, error)
+}
+
+type UserUpdater interface {
+	// UpdatePassword stores passHash and bumps the user's version, provided
+	// the stored version still equals version. Otherwise it returns
+	// storage.ErrVersionConflict.
+	UpdatePassword(
+		ctx context.Context,
+		userID int64,
+		passHash []byte,
+		version int64,
+		changedAt time.Time,
+	) error
+	// UpdateProfile stores the profile fields under the same version rule
+	// as UpdatePassword.
+	UpdateProfile(
+		ctx context.Context,
+		userID int64,
+		displayName string,
+		metadata map[string]string,
+		version int64,
+		updatedAt time.Time,
+	) error
+	// MarkForRehash flags the users for a rehash on their next login.
+	MarkForRehash(
+		ctx context.Context,
+		userIDs []int64,
+		markedAt time.Time,
+	) error
+	// Rehash replaces the hash of an unchanged password and clears the
+	// rehash flag, under the same version rule as UpdatePassword.
+	Rehash(
+		ctx context.Context,
+		userID int64,
+		passHash []byte,
+		version int64,
+		rehashedAt time.Time,
+	) error
+	// RehashCounts returns how many flagged users are still pending and how
+	// many were rehashed.
+	RehashCounts(ctx context.Context) (pending int64, completed int64, err error)
 }
 
 type AppProvider interface {
@@ -53,14 +192,49 @@ func New(
 	userProvider UserProvider,
 	appProvider AppProvider,
 	tokenTTL time.Duration,
+	opts ...Option,
 ) *Auth {
-
-	return &Auth{
+	auth := &Auth{
 		log:          log,
 		userSaver:    userSaver,
 		userProvider: userProvider,
 		appProvider:  appProvider,
 		tokenTTL:     tokenTTL,
+		hasher:       NewBcryptHasher(bcrypt.DefaultCost),
+		now:          time.Now,
+		random:       rand.Reader,
+		metrics:      nopMetrics{},
+	}
+
+	for _, opt := range opts {
+		opt(auth)
+	}
+
+	if auth.testMode && !testModeAllowed(auth.env) {
+		panic(fmt.Sprintf("auth: test mode is only allowed in the %s and %s environments, got %q", envLocal, envTest, auth.env))
+	}
+
+	return auth
+}
+
+// WithUserUpdater enables changes to existing users, such as ChangePassword.
+func WithUserUpdater(updater UserUpdater) Option {
+	return func(auth *Auth) {
+		auth.userUpdater = updater
+	}
+}
+
+// WithRolesClaim adds the user's roles to tokens as the "roles" claim.
+func WithRolesClaim() Option {
+	return func(auth *Auth) {
+		auth.rolesClaim = true
+	}
+}
+
+// WithClock replaces the clock used by the service, e.g. in tests.
+func WithClock(now func() time.Time) Option {
+	return func(auth *Auth) {
+		auth.now = now
 	}
 }
 
@@ -68,22 +242,40 @@ var (
 	ErrInvalidCredentials = errors.New("invalID credentials")
 	ErrInvalidAppID       = errors.New("invalid appID")
 	ErrUserExists         = errors.New("user already exists")
+	ErrNotConfigured      = errors.New("feature is not configured")
 )
 
 func (auth *Auth) Login(
 	ctx context.Context,
 	email string,
-	password []byte,
+	password string,
 	appID int32,
-) (string, error) {
+	opts ...LoginOption,
+) (*LoginResult, error) {
 	op := "auth.Login"
 
+	typedEmail := email
+	email = normalizeEmail(email)
+
+	var options loginOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	log := auth.log.With(
 		slog.String("op", op),
 		slog.String("email", email),
 	)
 
-	user, err := auth.userProvider.User(ctx, email)
+	if err := auth.checkIPBlocked(ctx, log); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.checkCaptcha(ctx, log, email, options.captchaToken); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.userByEmail(ctx, email, typedEmail)
 
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
@@ -92,44 +284,232 @@ func (auth *Auth) Login(
 				Value: slog.StringValue(err.Error()),
 			})
 
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+			auth.recordLoginFailure(ctx, log, email)
+			auth.audit(ctx, models.AuditEvent{
+				Type:    AuditLoginFailed,
+				AppId:   appID,
+				Details: map[string]string{"email": email, "reason": "user not found"},
+			})
+
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 
 		log.Error("failed to get user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
 
-		return "", fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err = bcrypt.CompareHashAndPassword(user.PassHash, password); err != nil {
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	if !user.DeletedAt.IsZero() {
+		log.Info("login to deleted account")
+
+		auth.recordLoginFailure(ctx, log, email)
+
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if len(user.PassHash) == 0 {
+		log.Info("password login on account without password")
+
+		return nil, fmt.Errorf("%s: %w", op, ErrPasswordLoginUnavailable)
+	}
+
+	if err = auth.comparePassword(ctx, user.PassHash, password); err != nil {
+		if errors.Is(err, ErrTimeout) {
+			log.Warn("no password hashing slot available")
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if errors.Is(err, ErrUnknownPepper) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		auth.recordLoginFailure(ctx, log, email)
+		auth.audit(ctx, models.AuditEvent{
+			Type:    AuditLoginFailed,
+			UserId:  int64(user.Id),
+			AppId:   appID,
+			Details: map[string]string{"reason": "invalid credentials"},
+		})
+
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
 
+	auth.resetLoginFailures(ctx, log, email)
+
+	auth.rehashIfNeeded(ctx, log, user, password)
+
 	app, err := auth.appProvider.App(ctx, appID)
 
 	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
 		log.Error("failed to get app", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
 
-		return "", fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	token, err := jwt.NewToken(user, app, auth.tokenTTL)
+	result, err := auth.issueIdempotentLogin(ctx, log, user, app, options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
+	return result, nil
+}
+
+// issueLogin starts a session for an authenticated user in app and mints
+// its access token.
+func (auth *Auth) issueLogin(
+	ctx context.Context,
+	log *slog.Logger,
+	user *models.User,
+	app *models.App,
+	options loginOptions,
+) (*LoginResult, error) {
+	sessionID, err := auth.newID()
+	if err != nil {
+		log.Error("failed to generate session id", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	tokenID, err := auth.newID()
+	if err != nil {
+		log.Error("failed to generate token id", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	tokenOpts, err := auth.tokenOptions(ctx, log, user, app, sessionID, tokenID, options.resources)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTTL := auth.accessTTL(app, user)
+	refreshTTL := auth.refreshTTL(app)
+
+	now := auth.now()
+
+	token, err := auth.newToken(log, user, app, now, accessTTL, tokenOpts...)
 	if err != nil {
 		log.Error("failed to create token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
 
-		return "", fmt.Errorf("%s: %w", op, err)
+		return nil, err
+	}
+
+	if err := auth.startSession(ctx, log, sessionID, int64(user.Id), app, refreshTTL); err != nil {
+		if errors.Is(err, ErrSessionLimitReached) {
+			log.Warn("session limit reached")
+
+			return nil, err
+		}
+
+		log.Error("failed to save session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	auth.audit(ctx, models.AuditEvent{
+		Type:    AuditLoginSucceeded,
+		UserId:  int64(user.Id),
+		AppId:   app.Id,
+		Details: map[string]string{"sessionID": sessionID},
+	})
+
+	auth.recordRotation(ctx, sessionID, tokenID, now, RotationIssued)
+	auth.emit(ctx, models.Event{
+		Type:      EventLoggedIn,
+		UserId:    int64(user.Id),
+		AppId:     app.Id,
+		SessionId: sessionID,
+	})
+	auth.emit(ctx, models.Event{
+		Type:      EventTokenIssued,
+		UserId:    int64(user.Id),
+		AppId:     app.Id,
+		SessionId: sessionID,
+		TokenId:   tokenID,
+	})
+
+	result := &LoginResult{
+		Token:            token,
+		ExpiresAt:        now.Add(accessTTL),
+		SessionID:        sessionID,
+		SessionExpiresAt: now.Add(refreshTTL),
+		TokenID:          tokenID,
+	}
+
+	if auth.csrfKey != nil {
+		result.CSRFToken = auth.csrfToken(sessionID)
+	}
+
+	return result, nil
+}
+
+// tokenOptions returns the claims of an access token for user in app
+// within the session.
+func (auth *Auth) tokenOptions(
+	ctx context.Context,
+	log *slog.Logger,
+	user *models.User,
+	app *models.App,
+	sessionID string,
+	tokenID string,
+	resources []string,
+) ([]jwt.Option, error) {
+	tokenOpts := []jwt.Option{jwt.WithSessionID(sessionID), jwt.WithTokenID(tokenID)}
+
+	if auth.rolesClaim && len(user.Roles) > 0 {
+		tokenOpts = append(tokenOpts, jwt.WithRoles(user.Roles))
+	}
+
+	if user.TenantID != "" {
+		tokenOpts = append(tokenOpts, jwt.WithTenantID(user.TenantID))
 	}
 
-	return token, nil
+	if auth.pseudonymClaim {
+		tokenOpts = append(tokenOpts, jwt.WithPseudonymousID(auth.PseudonymousID(int64(user.Id))))
+	}
+
+	if scopes := app.ScopesFor(user.Roles); len(scopes) > 0 {
+		tokenOpts = append(tokenOpts, jwt.WithScopes(scopes))
+	}
+
+	if len(resources) > 0 {
+		tokenOpts = append(tokenOpts, jwt.WithResources(resources))
+	}
+
+	if app.BindFingerprint {
+		fingerprint, ok := clientinfo.Fingerprint(ctx)
+		if !ok {
+			log.Warn("client fingerprint missing for app binding tokens")
+
+			return nil, ErrFingerprintRequired
+		}
+
+		tokenOpts = append(tokenOpts, jwt.WithFingerprint(fingerprint))
+	}
+
+	return tokenOpts, nil
 }
 
 func (auth *Auth) RegisterNewUser(
 	ctx context.Context,
 	email string,
 	password string,
+	opts ...RegisterOption,
 ) (int64, error) {
 	const op = "auth.RegisterNewUser"
 
+	email = normalizeEmail(email)
+
+	var options registerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	log := auth.log.With(
 		slog.String("op", op),
 		slog.String("email", email),
@@ -137,7 +517,41 @@ func (auth *Auth) RegisterNewUser(
 
 	log.Info("registering new user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := auth.checkIPBlocked(ctx, log); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ip, ok := clientinfo.IP(ctx); ok && auth.registrationLimiter != nil {
+		allowed, err := auth.registrationLimiter.Allow(ctx, "register:"+ip)
+		if err != nil {
+			log.Error("failed to check registration rate limit", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !allowed {
+			log.Warn("registration rate limited", slog.String("ip", ip))
+
+			return 0, fmt.Errorf("%s: %w", op, ErrRateLimited)
+		}
+	}
+
+	if err := auth.checkNewPassword(email, password); err != nil {
+		log.Warn("password rejected", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.checkEmailDomain(ctx, log, email); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := auth.registrationApp(ctx, options)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.hashPassword(ctx, password)
 
 	if err != nil {
 		log.Error("failed to generate password hash", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
@@ -145,21 +559,30 @@ func (auth *Auth) RegisterNewUser(
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	userId, err := auth.userSaver.SaveUser(ctx, email, passHash)
+	userId, err := auth.saveUser(ctx, email, passHash)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Warn("user already exists", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
 
-			return 0, ErrUserExists
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
 
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := auth.sendVerification(ctx, log, userId, email, app); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.audit(ctx, models.AuditEvent{
+		Type:   AuditUserRegistered,
+		UserId: userId,
+	})
+
 	return userId, nil
 }
 
-func (auth *Auth) isAdmin(ctx context.Context, userID int64) (bool, error) {
+func (auth *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	op := "auth.IsAdmin"
 
 	log := auth.log.With(
@@ -177,10 +600,38 @@ func (auth *Auth) isAdmin(ctx context.Context, userID int64) (bool, error) {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Warn("user not found", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
 
-			return false, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return isAdmin, nil
 }
+
+// normalizeEmail trims and lower-cases email so lookups don't depend on how
+// the user typed it.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// userByEmail reads the user by the normalized email. Accounts registered
+// before emails were normalized may be stored as typed, so a miss is
+// retried with the email exactly as given.
+func (auth *Auth) userByEmail(ctx context.Context, normalized, typed string) (*models.User, error) {
+	user, err := auth.readUser(ctx, normalized)
+	if errors.Is(err, storage.ErrUserNotFound) && typed != normalized {
+		return auth.readUser(ctx, typed)
+	}
+
+	return user, err
+}
+
+// newID returns a random identifier for sessions and tokens.
+func (auth *Auth) newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(auth.random, b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	jwt "sso/internal/lib"
+	"sso/internal/storage"
+	"strings"
+)
+
+// AuthzExplanation describes why a token does or does not grant a scope.
+type AuthzExplanation struct {
+	Scope   string
+	Granted bool
+	// Scopes and Roles are those carried by the token.
+	Scopes []string
+	Roles  []string
+	// MissingScopes lists the required scopes the token lacks.
+	MissingScopes []string
+	// GrantingRoles lists the app roles that would grant the missing scopes.
+	GrantingRoles []string
+}
+
+// ExplainAuthorization validates the token and reports whether it grants
+// requiredScope in appID, and if not, what is missing. It is meant for
+// debugging permission errors, not for making access decisions.
+func (auth *Auth) ExplainAuthorization(
+	ctx context.Context,
+	tokenString string,
+	appID int32,
+	requiredScope string,
+) (AuthzExplanation, error) {
+	const op = "auth.ExplainAuthorization"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("appID", int(appID)),
+		slog.String("scope", requiredScope),
+	)
+
+	claims, err := auth.ValidateToken(ctx, tokenString, appID)
+	if err != nil {
+		return AuthzExplanation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := auth.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return AuthzExplanation{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return AuthzExplanation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scope, _ := claims.Raw[jwt.ClaimScope].(string)
+
+	explanation := AuthzExplanation{
+		Scope:  requiredScope,
+		Scopes: strings.Fields(scope),
+		Roles:  claimStrings(claims.Raw[jwt.ClaimRoles]),
+	}
+
+	if slices.Contains(explanation.Scopes, requiredScope) {
+		explanation.Granted = true
+
+		return explanation, nil
+	}
+
+	explanation.MissingScopes = []string{requiredScope}
+
+	for role, scopes := range app.RoleScopes {
+		if slices.Contains(scopes, requiredScope) {
+			explanation.GrantingRoles = append(explanation.GrantingRoles, role)
+		}
+	}
+
+	slices.Sort(explanation.GrantingRoles)
+
+	return explanation, nil
+}
+
+// claimStrings returns the strings of a decoded JSON array claim.
+func claimStrings(v any) []string {
+	values, _ := v.([]any)
+
+	var out []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"testing"
+)
+
+func TestExplainAuthorization(t *testing.T) {
+	tests := []struct {
+		name        string
+		roles       []string
+		scope       string
+		wantGranted bool
+		wantScopes  []string
+		wantMissing []string
+		wantGrantBy []string
+	}{
+		{
+			name:        "granted",
+			roles:       []string{"editor"},
+			scope:       "docs:write",
+			wantGranted: true,
+			wantScopes:  []string{"docs:read", "docs:write"},
+		},
+		{
+			name:        "missing",
+			roles:       []string{"viewer"},
+			scope:       "docs:write",
+			wantScopes:  []string{"docs:read"},
+			wantMissing: []string{"docs:write"},
+			wantGrantBy: []string{"admin", "editor"},
+		},
+		{
+			name:        "granted by no role",
+			scope:       "billing:read",
+			wantMissing: []string{"billing:read"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithRolesClaim())
+			f.apps.update(testAppID, func(app *models.App) {
+				app.RoleScopes = map[string][]string{
+					"viewer": {"docs:read"},
+					"editor": {"docs:read", "docs:write"},
+					"admin":  {"docs:write", "users:manage"},
+				}
+			})
+
+			user := f.addUser(t, "user@example.com", testPassword)
+			if err := f.users.update(int64(user.Id), user.Version, func(user *models.User) { user.Roles = tt.roles }); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+
+			token := f.login(t, "user@example.com").Token
+
+			got, err := f.auth.ExplainAuthorization(context.Background(), token, testAppID, tt.scope)
+			if err != nil {
+				t.Fatalf("ExplainAuthorization: %v", err)
+			}
+
+			if got.Granted != tt.wantGranted {
+				t.Errorf("Granted = %v, want %v", got.Granted, tt.wantGranted)
+			}
+
+			if !slices.Equal(got.Scopes, tt.wantScopes) {
+				t.Errorf("Scopes = %v, want %v", got.Scopes, tt.wantScopes)
+			}
+
+			if !slices.Equal(got.Roles, tt.roles) {
+				t.Errorf("Roles = %v, want %v", got.Roles, tt.roles)
+			}
+
+			if !slices.Equal(got.MissingScopes, tt.wantMissing) {
+				t.Errorf("MissingScopes = %v, want %v", got.MissingScopes, tt.wantMissing)
+			}
+
+			if !slices.Equal(got.GrantingRoles, tt.wantGrantBy) {
+				t.Errorf("GrantingRoles = %v, want %v", got.GrantingRoles, tt.wantGrantBy)
+			}
+		})
+	}
+}
+
+func TestExplainAuthorizationInvalidToken(t *testing.T) {
+	f := newFixture(t)
+
+	if _, err := f.auth.ExplainAuthorization(context.Background(), "not-a-token", testAppID, "docs:read"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ExplainAuthorization() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
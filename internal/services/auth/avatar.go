@@ -0,0 +1,14 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// AvatarHash returns the Gravatar hash of email: the hex SHA-256 of the
+// email normalized the same way as on login and registration.
+func (auth *Auth) AvatarHash(email string) string {
+	hash := sha256.Sum256([]byte(normalizeEmail(email)))
+
+	return hex.EncodeToString(hash[:])
+}
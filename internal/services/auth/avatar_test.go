@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAvatarHash(t *testing.T) {
+	const want = "973dfe463ec85785f5f95af5ba3906eedb2d931c24e69824a89ea65dba4e813b"
+
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{name: "normalized", email: "test@example.com"},
+		{name: "mixed case", email: "Test@Example.COM"},
+		{name: "surrounding spaces", email: "  test@example.com "},
+	}
+
+	f := newFixture(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.auth.AvatarHash(tt.email); got != want {
+				t.Fatalf("AvatarHash(%q) = %s, want %s", tt.email, got, want)
+			}
+		})
+	}
+}
+
+func TestLoginNormalizesEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  string
+		typed   string
+		wantErr error
+	}{
+		{name: "exact", stored: "user@example.com", typed: "user@example.com"},
+		{name: "typed in other case", stored: "user@example.com", typed: " User@Example.com"},
+		{name: "legacy account stored as typed", stored: "Legacy@Example.com", typed: "Legacy@Example.com"},
+		{name: "legacy account typed differently", stored: "Legacy@Example.com", typed: "legacy@example.com", wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.addUser(t, tt.stored, testPassword)
+
+			_, err := f.auth.Login(context.Background(), tt.typed, testPassword, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
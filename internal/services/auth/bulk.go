@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+	"strconv"
+	"sync"
+)
+
+const defaultBulkBatchSize = 100
+
+// BulkOptions tunes a bulk operation. Zero values pick the defaults.
+type BulkOptions struct {
+	// BatchSize is the number of users per batch, 100 by default.
+	BatchSize int
+	// Concurrency caps the batches running at once, 1 by default.
+	Concurrency int
+}
+
+// BulkResult reports the progress of a bulk operation. Every audit event
+// of the run carries CorrelationID.
+type BulkResult struct {
+	CorrelationID string
+	Processed     int
+	// Failed holds the error for every user the action failed for.
+	Failed map[int64]error
+}
+
+// BulkAction is applied to every user of a bulk operation.
+type BulkAction func(ctx context.Context, userID int64) error
+
+// RunBulk applies action to userIDs in batches, running up to
+// opts.Concurrency batches at once, and records one audit event per batch.
+// Failures for single users are collected in the result. If ctx is
+// cancelled, no further users are processed and the partial result is
+// returned with the context error. The caller must be an admin.
+func (auth *Auth) RunBulk(
+	ctx context.Context,
+	operation string,
+	userIDs []int64,
+	action BulkAction,
+	opts BulkOptions,
+) (*BulkResult, error) {
+	const op = "auth.RunBulk"
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	correlationID, err := auth.newID()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("operation", operation),
+		slog.String("correlationID", correlationID),
+		slog.Int("users", len(userIDs)),
+	)
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBulkBatchSize
+	}
+
+	slots := make(chan struct{}, max(opts.Concurrency, 1))
+
+	result := &BulkResult{
+		CorrelationID: correlationID,
+		Failed:        make(map[int64]error),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	log.Info("bulk operation started")
+
+	for batch, start := 0, 0; start < len(userIDs); batch, start = batch+1, start+batchSize {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		ids := userIDs[start:min(start+batchSize, len(userIDs))]
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			processed, failed := auth.runBulkBatch(ctx, ids, action)
+
+			mu.Lock()
+			result.Processed += processed
+			for id, err := range failed {
+				result.Failed[id] = err
+			}
+			mu.Unlock()
+
+			auth.auditBulkBatch(ctx, operation, correlationID, batch, processed, len(failed))
+		}()
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		log.Warn("bulk operation cancelled", slog.Int("processed", result.Processed))
+
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("bulk operation finished",
+		slog.Int("processed", result.Processed),
+		slog.Int("failed", len(result.Failed)),
+	)
+
+	return result, nil
+}
+
+func (auth *Auth) runBulkBatch(ctx context.Context, ids []int64, action BulkAction) (int, map[int64]error) {
+	processed := 0
+	failed := make(map[int64]error)
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err := action(ctx, id); err != nil {
+			failed[id] = err
+		}
+
+		processed++
+	}
+
+	return processed, failed
+}
+
+func (auth *Auth) auditBulkBatch(ctx context.Context, operation, correlationID string, batch, processed, failed int) {
+	adminID, _ := clientinfo.UserID(ctx)
+
+	// The batch is recorded even if the run was cancelled meanwhile.
+	auth.audit(context.WithoutCancel(ctx), models.AuditEvent{
+		Type:   AuditBulkOperation,
+		UserId: adminID,
+		Details: map[string]string{
+			"operation":     operation,
+			"correlationId": correlationID,
+			"batch":         strconv.Itoa(batch),
+			"processed":     strconv.Itoa(processed),
+			"failed":        strconv.Itoa(failed),
+		},
+	})
+}
+
+// BulkRevokeSessions signs out every listed user via RunBulk.
+func (auth *Auth) BulkRevokeSessions(ctx context.Context, userIDs []int64, opts BulkOptions) (*BulkResult, error) {
+	const op = "auth.BulkRevokeSessions"
+
+	if auth.sessionStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	result, err := auth.RunBulk(ctx, "revoke_sessions", userIDs, func(ctx context.Context, userID int64) error {
+		err := auth.revokeUserSessions(ctx, userID)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+
+		return err
+	}, opts)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
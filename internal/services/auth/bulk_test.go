@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestRunBulk(t *testing.T) {
+	userIDs := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	failure := errors.New("action failed")
+
+	tests := []struct {
+		name        string
+		opts        BulkOptions
+		wantBatches int
+	}{
+		{name: "defaults", wantBatches: 1},
+		{name: "batched", opts: BulkOptions{BatchSize: 3}, wantBatches: 4},
+		{name: "concurrent", opts: BulkOptions{BatchSize: 2, Concurrency: 3}, wantBatches: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &fakeAudit{}
+			f := newFixture(t, WithAuditStore(audit))
+
+			var (
+				mu               sync.Mutex
+				running, maxSeen int
+			)
+
+			action := func(_ context.Context, userID int64) error {
+				mu.Lock()
+				running++
+				maxSeen = max(maxSeen, running)
+				mu.Unlock()
+
+				defer func() {
+					mu.Lock()
+					running--
+					mu.Unlock()
+				}()
+
+				if userID%2 == 0 {
+					return failure
+				}
+
+				return nil
+			}
+
+			result, err := f.auth.RunBulk(f.addAdmin(t), "test_op", userIDs, action, tt.opts)
+			if err != nil {
+				t.Fatalf("RunBulk: %v", err)
+			}
+
+			if result.Processed != len(userIDs) {
+				t.Errorf("Processed = %d, want %d", result.Processed, len(userIDs))
+			}
+
+			if got := slices.Sorted(maps.Keys(result.Failed)); !slices.Equal(got, []int64{2, 4, 6, 8, 10}) {
+				t.Errorf("Failed = %v, want the even users", got)
+			}
+
+			if limit := max(tt.opts.Concurrency, 1); maxSeen > limit {
+				t.Errorf("%d batches ran at once, want at most %d", maxSeen, limit)
+			}
+
+			if len(audit.events) != tt.wantBatches {
+				t.Fatalf("%d audit events, want one per batch, %d", len(audit.events), tt.wantBatches)
+			}
+
+			for _, event := range audit.events {
+				if event.Type != AuditBulkOperation || event.Details["operation"] != "test_op" ||
+					event.Details["correlationId"] != result.CorrelationID {
+					t.Errorf("audit event = %+v, want a test_op batch of run %s", event, result.CorrelationID)
+				}
+			}
+		})
+	}
+}
+
+func TestRunBulkCancelled(t *testing.T) {
+	audit := &fakeAudit{}
+	f := newFixture(t, WithAuditStore(audit))
+
+	ctx, cancel := context.WithCancel(f.addAdmin(t))
+	defer cancel()
+
+	action := func(_ context.Context, userID int64) error {
+		if userID == 3 {
+			cancel()
+		}
+
+		return nil
+	}
+
+	result, err := f.auth.RunBulk(ctx, "test_op", []int64{1, 2, 3, 4, 5, 6}, action, BulkOptions{BatchSize: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunBulk() error = %v, want %v", err, context.Canceled)
+	}
+
+	if result == nil || result.Processed != 3 {
+		t.Fatalf("RunBulk() result = %+v, want 3 users processed", result)
+	}
+
+	// The batch cut short is still audited.
+	if len(audit.events) != 2 {
+		t.Fatalf("%d audit events, want 2", len(audit.events))
+	}
+}
+
+func TestRunBulkRequiresAdmin(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.auth.RunBulk(context.Background(), "test_op", []int64{1}, func(context.Context, int64) error { return nil }, BulkOptions{})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("RunBulk() error = %v, want %v", err, ErrPermissionDenied)
+	}
+}
+
+func TestBulkRevokeSessions(t *testing.T) {
+	sessions := newFakeSessions()
+	f := newFixture(t, WithSessionStore(sessions))
+	sessions.users = f.users
+
+	user := f.addUser(t, "user@example.com", testPassword)
+	result := f.login(t, "user@example.com")
+
+	bulk, err := f.auth.BulkRevokeSessions(f.addAdmin(t), []int64{int64(user.Id), 404}, BulkOptions{})
+	if err != nil {
+		t.Fatalf("BulkRevokeSessions: %v", err)
+	}
+
+	if bulk.Processed != 2 || len(bulk.Failed) != 1 || !errors.Is(bulk.Failed[404], ErrUserNotFound) {
+		t.Fatalf("BulkRevokeSessions() = %+v, want the unknown user failed with %v", bulk, ErrUserNotFound)
+	}
+
+	if _, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID); !errors.Is(err, ErrSessionNotActive) {
+		t.Fatalf("ValidateToken() error = %v, want %v", err, ErrSessionNotActive)
+	}
+
+	if _, err := newFixture(t).auth.BulkRevokeSessions(context.Background(), nil, BulkOptions{}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("BulkRevokeSessions() error = %v, want %v", err, ErrNotConfigured)
+	}
+}
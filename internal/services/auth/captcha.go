@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/clientinfo"
+)
+
+var (
+	ErrCaptchaRequired = errors.New("captcha required")
+	ErrInvalidCaptcha  = errors.New("invalid captcha")
+)
+
+// CaptchaVerifier checks a CAPTCHA response token.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token string, ip string) (bool, error)
+}
+
+// NopCaptchaVerifier accepts every CAPTCHA token.
+type NopCaptchaVerifier struct{}
+
+func (NopCaptchaVerifier) Verify(context.Context, string, string) (bool, error) {
+	return true, nil
+}
+
+// FailureCounter counts recent failures per key.
+type FailureCounter interface {
+	Failures(ctx context.Context, key string) (int, error)
+	RecordFailure(ctx context.Context, key string) error
+	ResetFailures(ctx context.Context, key string) error
+}
+
+type captchaConfig struct {
+	verifier  CaptchaVerifier
+	failures  FailureCounter
+	threshold int
+}
+
+// WithCaptcha requires a CAPTCHA on Login once an account or client IP has
+// threshold recent failed attempts, see LoginWithCaptcha. A nil verifier
+// defaults to NopCaptchaVerifier.
+func WithCaptcha(verifier CaptchaVerifier, failures FailureCounter, threshold int) Option {
+	return func(auth *Auth) {
+		if verifier == nil {
+			verifier = NopCaptchaVerifier{}
+		}
+
+		auth.captcha = &captchaConfig{
+			verifier:  verifier,
+			failures:  failures,
+			threshold: threshold,
+		}
+	}
+}
+
+// LoginWithCaptcha passes the client's CAPTCHA response to Login.
+func LoginWithCaptcha(token string) LoginOption {
+	return func(options *loginOptions) {
+		options.captchaToken = token
+	}
+}
+
+// checkCaptcha demands and verifies a CAPTCHA if the account or IP failed
+// too often recently.
+func (auth *Auth) checkCaptcha(ctx context.Context, log *slog.Logger, email string, token string) error {
+	if auth.captcha == nil {
+		return nil
+	}
+
+	ip, _ := clientinfo.IP(ctx)
+
+	failures := 0
+	for _, key := range loginFailureKeys(email, ip) {
+		n, err := auth.captcha.failures.Failures(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to count login failures: %w", err)
+		}
+
+		failures = max(failures, n)
+	}
+
+	if failures < auth.captcha.threshold {
+		return nil
+	}
+
+	if token == "" {
+		log.Info("captcha required", slog.Int("failures", failures))
+
+		return ErrCaptchaRequired
+	}
+
+	ok, err := auth.captcha.verifier.Verify(ctx, token, ip)
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %w", err)
+	}
+
+	if !ok {
+		log.Warn("invalid captcha")
+
+		return ErrInvalidCaptcha
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt for the account and the IP.
+func (auth *Auth) recordLoginFailure(ctx context.Context, log *slog.Logger, email string) {
+	if auth.captcha == nil {
+		return
+	}
+
+	ip, _ := clientinfo.IP(ctx)
+
+	for _, key := range loginFailureKeys(email, ip) {
+		if err := auth.captcha.failures.RecordFailure(ctx, key); err != nil {
+			log.Error("failed to record login failure", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+		}
+	}
+}
+
+// resetLoginFailures clears the account's failures after a successful login.
+// The IP count is kept, as an IP may be shared with an attacker.
+func (auth *Auth) resetLoginFailures(ctx context.Context, log *slog.Logger, email string) {
+	if auth.captcha == nil {
+		return
+	}
+
+	if err := auth.captcha.failures.ResetFailures(ctx, "login:email:"+email); err != nil {
+		log.Error("failed to reset login failures", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+	}
+}
+
+func loginFailureKeys(email, ip string) []string {
+	keys := []string{"login:email:" + email}
+	if ip != "" {
+		keys = append(keys, "login:ip:"+ip)
+	}
+
+	return keys
+}
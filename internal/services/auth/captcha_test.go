@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/ratelimit"
+	"testing"
+	"time"
+)
+
+// fakeCaptcha accepts the token "solved".
+type fakeCaptcha struct{}
+
+func (fakeCaptcha) Verify(_ context.Context, token string, _ string) (bool, error) {
+	return token == "solved", nil
+}
+
+func TestLoginCaptcha(t *testing.T) {
+	tests := []struct {
+		name string
+		// failedEmail and failedIP are used for the two failed attempts.
+		failedEmail string
+		failedIP    string
+		captcha     string
+		wantErr     error
+	}{
+		{name: "below threshold", failedEmail: "other@example.com", failedIP: "10.0.0.9"},
+		{name: "account over threshold", failedEmail: "user@example.com", failedIP: "10.0.0.9", wantErr: ErrCaptchaRequired},
+		{name: "ip over threshold", failedEmail: "other@example.com", failedIP: "10.0.0.1", wantErr: ErrCaptchaRequired},
+		{name: "solved captcha", failedEmail: "user@example.com", failedIP: "10.0.0.1", captcha: "solved"},
+		{name: "wrong captcha", failedEmail: "user@example.com", failedIP: "10.0.0.1", captcha: "guess", wantErr: ErrInvalidCaptcha},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithCaptcha(fakeCaptcha{}, ratelimit.NewFailureCounter(time.Hour), 2))
+			f.addUser(t, "user@example.com", testPassword)
+			f.addUser(t, "other@example.com", testPassword)
+
+			failedCtx := clientinfo.WithIP(context.Background(), tt.failedIP)
+			for range 2 {
+				if _, err := f.auth.Login(failedCtx, tt.failedEmail, "wrong", testAppID); !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("failed Login() error = %v, want %v", err, ErrInvalidCredentials)
+				}
+			}
+
+			ctx := clientinfo.WithIP(context.Background(), "10.0.0.1")
+
+			_, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID, LoginWithCaptcha(tt.captcha))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoginCaptchaResetOnSuccess(t *testing.T) {
+	f := newFixture(t, WithCaptcha(fakeCaptcha{}, ratelimit.NewFailureCounter(time.Hour), 2))
+	f.addUser(t, "user@example.com", testPassword)
+	ctx := context.Background()
+
+	for range 2 {
+		_, _ = f.auth.Login(ctx, "user@example.com", "wrong", testAppID)
+	}
+
+	if _, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID, LoginWithCaptcha("solved")); err != nil {
+		t.Fatalf("Login with captcha: %v", err)
+	}
+
+	if _, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID); err != nil {
+		t.Fatalf("Login after reset: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInsufficientScope is returned by ValidateToken for tokens lacking a
+// scope required with RequireScope.
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// Bearer challenge error codes, see RFC 6750 section 3.1.
+const (
+	BearerInvalidRequest    = "invalid_request"
+	BearerInvalidToken      = "invalid_token"
+	BearerInsufficientScope = "insufficient_scope"
+)
+
+// BearerChallenge holds the parameters of a WWW-Authenticate header for
+// the Bearer scheme.
+type BearerChallenge struct {
+	Realm       string
+	Error       string
+	Description string
+	// Scope lists the scopes required, for insufficient_scope challenges.
+	Scope string
+}
+
+// NewBearerChallenge maps a token validation error to the challenge the
+// HTTP layer should send. A nil or unrelated error yields a challenge
+// without an error code, as for requests that carried no token.
+func NewBearerChallenge(err error) BearerChallenge {
+	switch {
+	case err == nil:
+		return BearerChallenge{}
+	case errors.Is(err, ErrTokenExpired):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token expired"}
+	case errors.Is(err, ErrTokenRevoked), errors.Is(err, ErrSessionNotActive):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token was revoked"}
+	case errors.Is(err, ErrFingerprintMismatch):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token is bound to another client"}
+	case errors.Is(err, ErrResourceMismatch):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token is not valid for this resource"}
+	case errors.Is(err, ErrTokenVersionUnsupported):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token format is no longer supported"}
+	case errors.Is(err, ErrTenantMismatch):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token belongs to another tenant"}
+	case errors.Is(err, ErrUntrustedIssuer):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token issuer is not trusted"}
+	case errors.Is(err, ErrInvalidToken):
+		return BearerChallenge{Error: BearerInvalidToken, Description: "the access token is malformed or has an invalid signature"}
+	case errors.Is(err, ErrFingerprintRequired):
+		return BearerChallenge{Error: BearerInvalidRequest, Description: "a client fingerprint is required"}
+	case errors.Is(err, ErrInsufficientScope):
+		return BearerChallenge{Error: BearerInsufficientScope, Description: "the access token lacks the required scope"}
+	}
+
+	return BearerChallenge{}
+}
+
+// String formats the challenge as a WWW-Authenticate header value, like
+// `Bearer realm="sso", error="invalid_token", error_description="..."`.
+func (c BearerChallenge) String() string {
+	var params []string
+
+	add := func(name, value string) {
+		if value != "" {
+			params = append(params, name+`="`+quoteEscaper.Replace(value)+`"`)
+		}
+	}
+
+	add("realm", c.Realm)
+	add("scope", c.Scope)
+	add("error", c.Error)
+	add("error_description", c.Description)
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"testing"
+)
+
+func TestNewBearerChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantError string
+	}{
+		{name: "no error"},
+		{name: "unrelated error", err: errors.New("boom")},
+		{name: "expired", err: ErrTokenExpired, wantError: BearerInvalidToken},
+		{name: "revoked", err: ErrTokenRevoked, wantError: BearerInvalidToken},
+		{name: "session ended", err: ErrSessionNotActive, wantError: BearerInvalidToken},
+		{name: "malformed", err: ErrInvalidToken, wantError: BearerInvalidToken},
+		{name: "fingerprint mismatch", err: ErrFingerprintMismatch, wantError: BearerInvalidToken},
+		{name: "fingerprint required", err: ErrFingerprintRequired, wantError: BearerInvalidRequest},
+		{name: "unsupported version", err: ErrTokenVersionUnsupported, wantError: BearerInvalidToken},
+		{name: "insufficient scope", err: ErrInsufficientScope, wantError: BearerInsufficientScope},
+		{name: "wrapped", err: fmt.Errorf("auth.ValidateToken: %w", ErrTokenExpired), wantError: BearerInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := NewBearerChallenge(tt.err)
+
+			if challenge.Error != tt.wantError {
+				t.Fatalf("NewBearerChallenge().Error = %q, want %q", challenge.Error, tt.wantError)
+			}
+
+			if (challenge.Description != "") != (tt.wantError != "") {
+				t.Fatalf("NewBearerChallenge().Description = %q for error %q", challenge.Description, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestBearerChallengeString(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge BearerChallenge
+		want      string
+	}{
+		{name: "empty", want: "Bearer"},
+		{name: "realm only", challenge: BearerChallenge{Realm: "sso"}, want: `Bearer realm="sso"`},
+		{
+			name:      "all parameters",
+			challenge: BearerChallenge{Realm: "sso", Error: BearerInsufficientScope, Description: "missing scope", Scope: "docs:read docs:write"},
+			want:      `Bearer realm="sso", scope="docs:read docs:write", error="insufficient_scope", error_description="missing scope"`,
+		},
+		{
+			name:      "quoted values",
+			challenge: BearerChallenge{Description: `a "quoted" \ value`},
+			want:      `Bearer error_description="a \"quoted\" \\ value"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.challenge.String(); got != tt.want {
+				t.Fatalf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	f := newFixture(t)
+	f.apps.update(testAppID, func(app *models.App) {
+		app.RoleScopes = map[string][]string{"editor": {"docs:read", "docs:write"}}
+	})
+
+	user := f.addUser(t, "user@example.com", testPassword)
+	if err := f.users.update(int64(user.Id), user.Version, func(user *models.User) { user.Roles = []string{"editor"} }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	token := f.login(t, "user@example.com").Token
+
+	tests := []struct {
+		name    string
+		scopes  []string
+		wantErr error
+	}{
+		{name: "no scope required"},
+		{name: "granted scope", scopes: []string{"docs:write"}},
+		{name: "all granted", scopes: []string{"docs:read", "docs:write"}},
+		{name: "missing scope", scopes: []string{"docs:read", "docs:admin"}, wantErr: ErrInsufficientScope},
+		{name: "scope prefix", scopes: []string{"docs"}, wantErr: ErrInsufficientScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.auth.ValidateToken(context.Background(), token, testAppID, RequireScope(tt.scopes...))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil && NewBearerChallenge(err).Error != BearerInsufficientScope {
+				t.Fatalf("challenge for %v = %+v", err, NewBearerChallenge(err))
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var ErrClockRollback = errors.New("clock moved backwards")
+
+// clockRollback detects the clock jumping backwards between issued tokens,
+// which would mint tokens dated before ones already handed out.
+type clockRollback struct {
+	threshold time.Duration
+	refuse    bool
+	// lastIssued is the latest issue time seen, in Unix nanoseconds.
+	lastIssued *atomic.Int64
+}
+
+// WithClockRollbackDetection warns when a token would be issued more than
+// threshold before the latest issued one. With refuse set, such tokens are
+// not issued and ErrClockRollback is returned instead.
+func WithClockRollbackDetection(threshold time.Duration, refuse bool) Option {
+	return func(auth *Auth) {
+		auth.clockRollback = clockRollback{
+			threshold:  threshold,
+			refuse:     refuse,
+			lastIssued: new(atomic.Int64),
+		}
+	}
+}
+
+// check records now as issue time and reports rollbacks beyond the threshold.
+func (c clockRollback) check(log *slog.Logger, now time.Time) error {
+	if c.lastIssued == nil {
+		return nil
+	}
+
+	for {
+		last := c.lastIssued.Load()
+
+		if now.UnixNano() <= last {
+			behind := time.Duration(last - now.UnixNano())
+			if behind <= c.threshold {
+				return nil
+			}
+
+			log.Warn("clock moved backwards",
+				slog.Time("now", now),
+				slog.Time("lastIssued", time.Unix(0, last)),
+				slog.Bool("refused", c.refuse),
+			)
+
+			if c.refuse {
+				return ErrClockRollback
+			}
+
+			return nil
+		}
+
+		if c.lastIssued.CompareAndSwap(last, now.UnixNano()) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClockRollbackCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		refuse bool
+		// steps move the clock before each issue after the first.
+		steps   []time.Duration
+		wantErr []error
+	}{
+		{name: "forward", refuse: true, steps: []time.Duration{time.Second, time.Minute}, wantErr: []error{nil, nil}},
+		{name: "within grace", refuse: true, steps: []time.Duration{-30 * time.Second}, wantErr: []error{nil}},
+		{name: "beyond grace refused", refuse: true, steps: []time.Duration{-2 * time.Minute}, wantErr: []error{ErrClockRollback}},
+		{name: "beyond grace only warned", steps: []time.Duration{-2 * time.Minute}, wantErr: []error{nil}},
+		{
+			name:    "measured against latest issue",
+			refuse:  true,
+			steps:   []time.Duration{time.Hour, -90 * time.Second},
+			wantErr: []error{nil, ErrClockRollback},
+		},
+		{
+			name:    "recovered clock",
+			refuse:  true,
+			steps:   []time.Duration{-2 * time.Minute, 3 * time.Minute},
+			wantErr: []error{ErrClockRollback, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithClockRollbackDetection(time.Minute, tt.refuse))
+			c := f.auth.clockRollback
+
+			now := testEpoch
+			if err := c.check(f.auth.log, now); err != nil {
+				t.Fatalf("first check: %v", err)
+			}
+
+			for i, step := range tt.steps {
+				now = now.Add(step)
+
+				if err := c.check(f.auth.log, now); !errors.Is(err, tt.wantErr[i]) {
+					t.Fatalf("step %d: check() error = %v, want %v", i, err, tt.wantErr[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClockRollbackDisabled(t *testing.T) {
+	var c clockRollback
+
+	if err := c.check(discardLogger(), testEpoch); err != nil {
+		t.Fatalf("check() error = %v, want nil", err)
+	}
+}
+
+func TestLoginRefusedAfterClockRollback(t *testing.T) {
+	f := newFixture(t, WithClockRollbackDetection(time.Minute, true))
+	f.addUser(t, "user@example.com", testPassword)
+
+	f.login(t, "user@example.com")
+	f.clock.Advance(-time.Hour)
+
+	if _, err := f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID); !errors.Is(err, ErrClockRollback) {
+		t.Fatalf("Login() error = %v, want %v", err, ErrClockRollback)
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sso/internal/lib/bloom"
+	"strings"
+)
+
+var ErrCommonPassword = errors.New("password is too common")
+
+// CommonPasswordChecker flags passwords found on a common-password list.
+// The list is kept in a Bloom filter, so memory stays fixed regardless of
+// the list size; a small share of uncommon passwords may be rejected too.
+type CommonPasswordChecker struct {
+	filter *bloom.Filter
+}
+
+// NewCommonPasswordChecker loads a wordlist with one password per line.
+// expected is the approximate number of entries and, with fpRate, sizes
+// the filter.
+func NewCommonPasswordChecker(wordlist io.Reader, expected int, fpRate float64) (*CommonPasswordChecker, error) {
+	filter := bloom.New(expected, fpRate)
+
+	scanner := bufio.NewScanner(wordlist)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" {
+			continue
+		}
+
+		filter.Add([]byte(strings.ToLower(word)))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &CommonPasswordChecker{filter: filter}, nil
+}
+
+// IsCommon reports whether password is on the list, ignoring case.
+func (c *CommonPasswordChecker) IsCommon(password string) bool {
+	return c.filter.MayContain([]byte(strings.ToLower(password)))
+}
+
+// WithCommonPasswordChecker rejects new passwords found by checker.
+func WithCommonPasswordChecker(checker *CommonPasswordChecker) Option {
+	return func(auth *Auth) {
+		auth.commonPasswords = checker
+	}
+}
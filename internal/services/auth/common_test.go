@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCommonPasswordChecker(t *testing.T) {
+	checker, err := NewCommonPasswordChecker(strings.NewReader("password\n  Qwerty123 \n\nletmein\n"), 3, 0.0001)
+	if err != nil {
+		t.Fatalf("NewCommonPasswordChecker: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "password", want: true},
+		{password: "PASSWORD", want: true},
+		{password: "qwerty123", want: true},
+		{password: "letmein", want: true},
+		{password: testPassword, want: false},
+		{password: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := checker.IsCommon(tt.password); got != tt.want {
+				t.Fatalf("IsCommon(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsCommonPassword(t *testing.T) {
+	checker, err := NewCommonPasswordChecker(strings.NewReader("Summer2024!\n"), 1, 0.0001)
+	if err != nil {
+		t.Fatalf("NewCommonPasswordChecker: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		wantErr  error
+	}{
+		{password: "summer2024!", wantErr: ErrCommonPassword},
+		{password: testPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			f := newFixture(t, WithCommonPasswordChecker(checker))
+
+			if _, err := f.auth.RegisterNewUser(context.Background(), "user@example.com", tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterNewUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
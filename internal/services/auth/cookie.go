@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CookieConfig sets the attributes of cookies made by LoginCookie.
+type CookieConfig struct {
+	// Name defaults to "access_token".
+	Name   string
+	Domain string
+	// Path defaults to "/".
+	Path string
+	// SameSite defaults to http.SameSiteStrictMode.
+	SameSite http.SameSite
+	// Insecure drops the Secure attribute, for local development over
+	// plain HTTP only.
+	Insecure bool
+}
+
+// WithTokenCookie configures the cookies made by LoginCookie.
+func WithTokenCookie(config CookieConfig) Option {
+	return func(auth *Auth) {
+		auth.tokenCookie = config
+	}
+}
+
+// LoginCookie logs the user in like Login and also returns the access token
+// as an HttpOnly cookie that expires together with the token.
+func (auth *Auth) LoginCookie(
+	ctx context.Context,
+	email string,
+	password string,
+	appID int32,
+	opts ...LoginOption,
+) (*http.Cookie, *LoginResult, error) {
+	const op = "auth.LoginCookie"
+
+	result, err := auth.Login(ctx, email, password, appID, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return auth.newTokenCookie(result.Token, result.ExpiresAt), result, nil
+}
+
+func (auth *Auth) newTokenCookie(token string, expiresAt time.Time) *http.Cookie {
+	config := auth.tokenCookie
+
+	cookie := &http.Cookie{
+		Name:     config.Name,
+		Value:    token,
+		Domain:   config.Domain,
+		Path:     config.Path,
+		Expires:  expiresAt,
+		MaxAge:   int(expiresAt.Sub(auth.now()).Seconds()),
+		Secure:   !config.Insecure,
+		HttpOnly: true,
+		SameSite: config.SameSite,
+	}
+
+	if cookie.Name == "" {
+		cookie.Name = "access_token"
+	}
+
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+
+	// MaxAge 0 would mean no Max-Age at all, an already expired token
+	// must delete the cookie instead.
+	if cookie.MaxAge <= 0 {
+		cookie.MaxAge = -1
+	}
+
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteStrictMode
+	}
+
+	return cookie
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		config CookieConfig
+		want   http.Cookie
+	}{
+		{
+			name: "defaults",
+			want: http.Cookie{Name: "access_token", Path: "/", Secure: true, SameSite: http.SameSiteStrictMode},
+		},
+		{
+			name:   "configured",
+			config: CookieConfig{Name: "sso", Domain: "example.com", Path: "/app", SameSite: http.SameSiteLaxMode, Insecure: true},
+			want:   http.Cookie{Name: "sso", Domain: "example.com", Path: "/app", SameSite: http.SameSiteLaxMode},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithTokenCookie(tt.config))
+			f.addUser(t, "user@example.com", testPassword)
+
+			cookie, result, err := f.auth.LoginCookie(context.Background(), "user@example.com", testPassword, testAppID)
+			if err != nil {
+				t.Fatalf("LoginCookie: %v", err)
+			}
+
+			if err := cookie.Valid(); err != nil {
+				t.Fatalf("cookie is invalid: %v", err)
+			}
+
+			if cookie.Value != result.Token || !cookie.HttpOnly {
+				t.Errorf("cookie = %+v, want the HttpOnly access token", cookie)
+			}
+
+			if cookie.Name != tt.want.Name || cookie.Domain != tt.want.Domain || cookie.Path != tt.want.Path ||
+				cookie.Secure != tt.want.Secure || cookie.SameSite != tt.want.SameSite {
+				t.Errorf("cookie = %+v, want attributes of %+v", cookie, tt.want)
+			}
+
+			if !cookie.Expires.Equal(result.ExpiresAt) || cookie.MaxAge != int(testTokenTTL.Seconds()) {
+				t.Errorf("cookie expires %v, max age %d, want %v and %d", cookie.Expires, cookie.MaxAge, result.ExpiresAt, int(testTokenTTL.Seconds()))
+			}
+
+			claims, err := f.auth.ValidateToken(context.Background(), cookie.Value, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			if !claims.ExpiresAt.Equal(cookie.Expires) {
+				t.Errorf("token exp = %v, want the cookie expiry %v", claims.ExpiresAt, cookie.Expires)
+			}
+		})
+	}
+}
+
+func TestLoginCookieInvalidCredentials(t *testing.T) {
+	f := newFixture(t)
+	f.addUser(t, "user@example.com", testPassword)
+
+	cookie, _, err := f.auth.LoginCookie(context.Background(), "user@example.com", "wrong", testAppID)
+	if !errors.Is(err, ErrInvalidCredentials) || cookie != nil {
+		t.Fatalf("LoginCookie() = %v, %v, want no cookie and %v", cookie, err, ErrInvalidCredentials)
+	}
+}
+
+func TestTokenCookieExpired(t *testing.T) {
+	f := newFixture(t)
+
+	cookie := f.auth.newTokenCookie("token", testEpoch.Add(-time.Second))
+	if cookie.MaxAge != -1 {
+		t.Fatalf("MaxAge = %d, want -1 to delete the cookie", cookie.MaxAge)
+	}
+}
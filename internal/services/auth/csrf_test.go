@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoginCSRFToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantCSRF bool
+	}{
+		{name: "without key", wantCSRF: false},
+		{name: "with key", opts: []Option{WithCSRFKey([]byte("csrf-key"))}, wantCSRF: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.opts...)
+			f.addUser(t, "user@example.com", testPassword)
+
+			result := f.login(t, "user@example.com")
+
+			if got := result.CSRFToken != ""; got != tt.wantCSRF {
+				t.Fatalf("CSRFToken = %q, want set: %v", result.CSRFToken, tt.wantCSRF)
+			}
+		})
+	}
+}
+
+func TestValidateCSRF(t *testing.T) {
+	f := newFixture(t, WithCSRFKey([]byte("csrf-key")))
+	f.addUser(t, "user@example.com", testPassword)
+
+	result := f.login(t, "user@example.com")
+	other := f.login(t, "user@example.com")
+
+	tests := []struct {
+		name      string
+		sessionID string
+		token     string
+		wantErr   error
+	}{
+		{name: "valid", sessionID: result.SessionID, token: result.CSRFToken},
+		{name: "other session", sessionID: other.SessionID, token: result.CSRFToken, wantErr: ErrInvalidCSRFToken},
+		{name: "empty token", sessionID: result.SessionID, token: "", wantErr: ErrInvalidCSRFToken},
+		{name: "empty session", sessionID: "", token: result.CSRFToken, wantErr: ErrInvalidCSRFToken},
+		{name: "not base64", sessionID: result.SessionID, token: "!!!", wantErr: ErrInvalidCSRFToken},
+		{name: "tampered", sessionID: result.SessionID, token: tamper(result.CSRFToken), wantErr: ErrInvalidCSRFToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.auth.ValidateCSRF(tt.sessionID, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateCSRF() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCSRFWithoutKey(t *testing.T) {
+	f := newFixture(t)
+
+	if err := f.auth.ValidateCSRF("session", "token"); !errors.Is(err, ErrInvalidCSRFToken) {
+		t.Fatalf("ValidateCSRF() error = %v, want %v", err, ErrInvalidCSRFToken)
+	}
+}
+
+// tamper changes the first character of token.
+func tamper(token string) string {
+	if token[0] == 'A' {
+		return "B" + token[1:]
+	}
+
+	return "A" + token[1:]
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	jwt "sso/internal/lib"
+	"sso/internal/storage"
+)
+
+// IssueDownstreamToken exchanges validated source claims for a token signed
+// with the target app's secret. The new token carries only the user
+// identity and session, records the source app in "azp", and never outlives
+// the source token. A client fingerprint binding is carried over; target
+// apps that bind tokens reject unbound source tokens with
+// ErrFingerprintRequired.
+func (auth *Auth) IssueDownstreamToken(
+	ctx context.Context,
+	sourceClaims *TokenClaims,
+	targetAppID int32,
+) (string, error) {
+	const op = "auth.IssueDownstreamToken"
+
+	if sourceClaims == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", sourceClaims.UserID),
+		slog.Int("sourceAppID", int(sourceClaims.AppID)),
+		slog.Int("targetAppID", int(targetAppID)),
+	)
+
+	now := auth.now()
+
+	remaining := sourceClaims.ExpiresAt.Sub(now)
+	if remaining <= 0 {
+		return "", fmt.Errorf("%s: %w", op, ErrTokenExpired)
+	}
+
+	if err := auth.checkSession(ctx, sourceClaims.SessionID); err != nil {
+		if errors.Is(err, ErrSessionNotActive) {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	target, err := auth.appProvider.App(ctx, targetAppID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("target app not found")
+
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The user is read for its TokenTTL override, so downstream tokens get
+	// the same lifetime as the user's logins.
+	user, err := auth.userProvider.UserByID(ctx, sourceClaims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found")
+
+			return "", fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	opts := []jwt.Option{jwt.WithAuthorizedParty(sourceClaims.AppID)}
+	if sourceClaims.SessionID != "" {
+		opts = append(opts, jwt.WithSessionID(sourceClaims.SessionID))
+	}
+
+	fingerprint, bound := jwt.Fingerprint(sourceClaims.Raw)
+	if bound {
+		opts = append(opts, jwt.WithFingerprint(fingerprint))
+	} else if target.BindFingerprint {
+		log.Warn("unbound source token for app binding tokens")
+
+		return "", fmt.Errorf("%s: %w", op, ErrFingerprintRequired)
+	}
+
+	token, err := auth.newToken(log, user, target, now, min(auth.accessTTL(target, user), remaining), opts...)
+	if err != nil {
+		log.Error("failed to create token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("downstream token issued")
+
+	return token, nil
+}
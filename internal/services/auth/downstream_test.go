@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	jwt "sso/internal/lib"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+const downstreamAppID int32 = 2
+
+func TestIssueDownstreamToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// sourceFP is the client fingerprint of the login, empty for none.
+		sourceFP   string
+		sourceBind bool
+		targetBind bool
+		// advance moves the clock between login and exchange.
+		advance time.Duration
+		revoke  bool
+		target  int32
+		wantErr error
+	}{
+		{name: "exchanged", target: downstreamAppID},
+		{name: "fingerprint carried over", sourceBind: true, targetBind: true, sourceFP: "fp-1", target: downstreamAppID},
+		{name: "unbound source for binding target", targetBind: true, target: downstreamAppID, wantErr: ErrFingerprintRequired},
+		{name: "expired source", advance: 2 * testTokenTTL, target: downstreamAppID, wantErr: ErrTokenExpired},
+		{name: "revoked session", revoke: true, target: downstreamAppID, wantErr: ErrSessionNotActive},
+		{name: "unknown target", target: 42, wantErr: ErrInvalidAppID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithSessionStore(newFakeSessions()))
+			f.apps.update(testAppID, func(app *models.App) { app.BindFingerprint = tt.sourceBind })
+			f.apps.add(models.App{Id: downstreamAppID, Secret: "downstream-secret", BindFingerprint: tt.targetBind})
+			f.addUser(t, "user@example.com", testPassword)
+
+			ctx := clientinfo.WithFingerprint(context.Background(), tt.sourceFP)
+
+			result, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID)
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			source, err := f.auth.ValidateToken(ctx, result.Token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken(source): %v", err)
+			}
+
+			if tt.revoke {
+				if err := f.auth.RevokeSession(ctx, result.SessionID); err != nil {
+					t.Fatalf("RevokeSession: %v", err)
+				}
+			}
+
+			f.clock.Advance(tt.advance)
+
+			token, err := f.auth.IssueDownstreamToken(ctx, source, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IssueDownstreamToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if _, err := f.auth.ValidateToken(ctx, token, testAppID); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("ValidateToken() for source app error = %v, want %v", err, ErrInvalidToken)
+			}
+
+			claims, err := f.auth.ValidateToken(ctx, token, downstreamAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken(downstream): %v", err)
+			}
+
+			if !jwt.ClaimEquals(claims.Raw, jwt.ClaimAuthorizedParty, testAppID) {
+				t.Errorf("azp = %v, want %d", claims.Raw[jwt.ClaimAuthorizedParty], testAppID)
+			}
+
+			if claims.SessionID != source.SessionID || claims.UserID != source.UserID {
+				t.Errorf("claims = %+v, want session and user of %+v", claims, source)
+			}
+
+			if claims.ExpiresAt.After(source.ExpiresAt) {
+				t.Errorf("ExpiresAt = %v, after source expiry %v", claims.ExpiresAt, source.ExpiresAt)
+			}
+
+			if fp, _ := jwt.Fingerprint(claims.Raw); fp != tt.sourceFP {
+				t.Errorf("fingerprint = %q, want %q", fp, tt.sourceFP)
+			}
+		})
+	}
+}
+
+func TestIssueDownstreamTokenNeverOutlivesSource(t *testing.T) {
+	f := newFixture(t)
+	f.apps.add(models.App{Id: downstreamAppID, Secret: "downstream-secret", AccessTTL: 24 * time.Hour})
+	f.addUser(t, "user@example.com", testPassword)
+
+	result := f.login(t, "user@example.com")
+
+	source, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	f.clock.Advance(testTokenTTL / 2)
+
+	token, err := f.auth.IssueDownstreamToken(context.Background(), source, downstreamAppID)
+	if err != nil {
+		t.Fatalf("IssueDownstreamToken: %v", err)
+	}
+
+	claims, err := f.auth.ValidateToken(context.Background(), token, downstreamAppID)
+	if err != nil {
+		t.Fatalf("ValidateToken(downstream): %v", err)
+	}
+
+	if !claims.ExpiresAt.Equal(source.ExpiresAt) {
+		t.Fatalf("ExpiresAt = %v, want the source expiry %v", claims.ExpiresAt, source.ExpiresAt)
+	}
+}
+
+func TestIssueDownstreamTokenWithoutClaims(t *testing.T) {
+	f := newFixture(t)
+
+	if _, err := f.auth.IssueDownstreamToken(context.Background(), nil, downstreamAppID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("IssueDownstreamToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrUndeliverableEmail = errors.New("email domain does not accept mail")
+
+const defaultDomainLookupTimeout = 2 * time.Second
+
+// DomainResolver looks up DNS records. *net.Resolver implements it.
+type DomainResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DomainCheck configures the email domain check done at registration.
+type DomainCheck struct {
+	Resolver DomainResolver
+	// Timeout bounds the lookups, 2 seconds if zero.
+	Timeout time.Duration
+	// FailClosed rejects registrations when the lookup itself fails. By
+	// default such errors are logged and the registration goes ahead.
+	FailClosed bool
+}
+
+// WithEmailDomainCheck makes RegisterNewUser reject emails whose domain has
+// neither MX nor A/AAAA records with ErrUndeliverableEmail.
+func WithEmailDomainCheck(check DomainCheck) Option {
+	return func(auth *Auth) {
+		if check.Timeout <= 0 {
+			check.Timeout = defaultDomainLookupTimeout
+		}
+
+		auth.domainCheck = &check
+	}
+}
+
+// checkEmailDomain reports whether the domain of email can receive mail,
+// following RFC 5321: MX records if there are any, otherwise the domain's
+// own address. A null MX (RFC 7505) marks a domain that accepts no mail.
+// SelfTest registrations are exempt, their domain never has records.
+func (auth *Auth) checkEmailDomain(ctx context.Context, log *slog.Logger, email string) error {
+	if auth.domainCheck == nil || isSelfTest(ctx) {
+		return nil
+	}
+
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 || at == len(email)-1 {
+		return ErrUndeliverableEmail
+	}
+
+	domain := email[at+1:]
+
+	ctx, cancel := context.WithTimeout(ctx, auth.domainCheck.Timeout)
+	defer cancel()
+
+	deliverable, err := lookupMailDomain(ctx, auth.domainCheck.Resolver, domain)
+	if err != nil {
+		log.Warn("failed to look up email domain",
+			slog.String("domain", domain),
+			slog.Bool("failClosed", auth.domainCheck.FailClosed),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		if auth.domainCheck.FailClosed {
+			return err
+		}
+
+		return nil
+	}
+
+	if !deliverable {
+		log.Warn("email domain has no mail records", slog.String("domain", domain))
+
+		return ErrUndeliverableEmail
+	}
+
+	return nil
+}
+
+func lookupMailDomain(ctx context.Context, resolver DomainResolver, domain string) (bool, error) {
+	mx, err := resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+
+	if len(mx) == 1 && mx[0].Host == "." {
+		return false, nil
+	}
+
+	if len(mx) > 0 {
+		return true, nil
+	}
+
+	hosts, err := resolver.LookupHost(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+
+	return len(hosts) > 0, nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
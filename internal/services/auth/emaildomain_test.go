@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestEmailDomainCheck(t *testing.T) {
+	lookupFailed := errors.New("resolver unreachable")
+
+	resolver := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"mail.example":   {{Host: "mx.mail.example.", Pref: 10}},
+			"nullmx.example": {{Host: "."}},
+		},
+		hosts: map[string][]string{
+			"host.example": {"192.0.2.1"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		email      string
+		resolver   *fakeResolver
+		failClosed bool
+		wantErr    error
+	}{
+		{name: "mx records", email: "user@mail.example", resolver: resolver},
+		{name: "address only", email: "user@host.example", resolver: resolver},
+		{name: "null mx", email: "user@nullmx.example", resolver: resolver, wantErr: ErrUndeliverableEmail},
+		{name: "no records", email: "user@nowhere.example", resolver: resolver, wantErr: ErrUndeliverableEmail},
+		{name: "lookup fails open", email: "user@mail.example", resolver: &fakeResolver{err: lookupFailed}},
+		{name: "lookup fails closed", email: "user@mail.example", resolver: &fakeResolver{err: lookupFailed}, failClosed: true, wantErr: lookupFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithEmailDomainCheck(DomainCheck{Resolver: tt.resolver, FailClosed: tt.failClosed}))
+
+			_, err := f.auth.RegisterNewUser(context.Background(), tt.email, testPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterNewUser() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if registered := len(f.users.byID) == 1; registered != (tt.wantErr == nil) {
+				t.Fatalf("user registered = %v, want %v", registered, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestEmailDomainCheckWithSelfTest(t *testing.T) {
+	resolver := &fakeResolver{}
+
+	f := newFixture(t, WithEnv(envTest), WithEmailDomainCheck(DomainCheck{Resolver: resolver, FailClosed: true}))
+	WithUserDeleter(f.users)(f.auth)
+	WithSelfTestApp(testAppID)(f.auth)
+
+	if err := f.auth.SelfTest(context.Background()); err != nil {
+		t.Fatalf("SelfTest() error = %v, want nil", err)
+	}
+
+	if len(f.users.byID) != 0 {
+		t.Fatalf("%d users left after SelfTest, want 0", len(f.users.byID))
+	}
+
+	// The exemption is limited to SelfTest: the same domain is refused for
+	// normal registrations.
+	_, err := f.auth.RegisterNewUser(context.Background(), "user@"+selfTestDomain, testPassword)
+	if !errors.Is(err, ErrUndeliverableEmail) {
+		t.Fatalf("RegisterNewUser() error = %v, want %v", err, ErrUndeliverableEmail)
+	}
+}
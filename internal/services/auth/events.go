@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+)
+
+// Event types recorded in the event log.
+const (
+	EventUserRegistered = "user_registered"
+	EventLoggedIn       = "logged_in"
+	EventTokenIssued    = "token_issued"
+	EventSessionRevoked = "session_revoked"
+	// EventUserSessionsRevoked is recorded when all sessions of a user are
+	// revoked at once, as on a password change.
+	EventUserSessionsRevoked = "user_sessions_revoked"
+	EventPasswordChanged     = "password_changed"
+	EventUserDeleted         = "user_deleted"
+)
+
+// EventStore is an append-only log of auth events for building read models.
+type EventStore interface {
+	// AppendEvent stores event under the next sequence number.
+	AppendEvent(ctx context.Context, event models.Event) error
+	// Events returns the events following afterSeq, oldest first, at most
+	// limit of them.
+	Events(ctx context.Context, afterSeq int64, limit int) ([]models.Event, error)
+}
+
+// UserEventSaver is implemented by user savers that can append the
+// registration event in the same transaction as the user.
+type UserEventSaver interface {
+	// SaveUserWithEvent saves the user like SaveUser and appends event,
+	// with its UserId set to the new user's ID, atomically.
+	SaveUserWithEvent(
+		ctx context.Context,
+		name string,
+		passHash []byte,
+		event models.Event,
+	) (userID int64, err error)
+}
+
+// WithEventStore records auth events in store. If the user saver implements
+// UserEventSaver, registrations and their event are written atomically.
+// Other events are appended right after the write they describe.
+func WithEventStore(store EventStore) Option {
+	return func(auth *Auth) {
+		auth.eventStore = store
+	}
+}
+
+// ReplayEvents returns up to limit events after sequence number afterSeq,
+// so consumers can rebuild their state from any point of the log. The
+// caller must be an admin.
+func (auth *Auth) ReplayEvents(ctx context.Context, afterSeq int64, limit int) ([]models.Event, error) {
+	const op = "auth.ReplayEvents"
+
+	if auth.eventStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := auth.eventStore.Events(ctx, afterSeq, pageLimit(limit))
+	if err != nil {
+		auth.log.Error("failed to read events",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// saveUser saves a new user, together with its registration event when the
+// saver supports it.
+func (auth *Auth) saveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	if saver, ok := auth.userSaver.(UserEventSaver); ok && auth.eventStore != nil {
+		return saver.SaveUserWithEvent(ctx, email, passHash, models.Event{
+			Type:       EventUserRegistered,
+			OccurredAt: auth.now(),
+		})
+	}
+
+	userID, err := auth.userSaver.SaveUser(ctx, email, passHash)
+	if err != nil {
+		return 0, err
+	}
+
+	auth.emit(ctx, models.Event{Type: EventUserRegistered, UserId: userID})
+
+	return userID, nil
+}
+
+// emit appends event to the event log. Failures are logged, the change the
+// event describes has already been made.
+func (auth *Auth) emit(ctx context.Context, event models.Event) {
+	if auth.eventStore == nil {
+		return
+	}
+
+	event.OccurredAt = auth.now()
+
+	if err := auth.eventStore.AppendEvent(ctx, event); err != nil {
+		auth.log.Error("failed to append event",
+			slog.String("type", event.Type),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+	}
+}
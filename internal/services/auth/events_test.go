@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestEventsOfAuthChanges(t *testing.T) {
+	events := &fakeEvents{}
+	f := newFixture(t, WithEventStore(events), WithSessionStore(newFakeSessions()), WithRotationStore(&fakeRotations{}))
+	WithUserPurger(f.users)(f.auth)
+
+	userID, err := f.auth.RegisterNewUser(context.Background(), "user@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("RegisterNewUser: %v", err)
+	}
+
+	first := f.login(t, "user@example.com")
+
+	if _, err := f.auth.RefreshToken(context.Background(), first.Token, testAppID); err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	if err := f.auth.RevokeSession(context.Background(), first.SessionID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	f.login(t, "user@example.com")
+
+	if err := f.auth.ChangePassword(context.Background(), userID, testPassword, "Another-Horse-43"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if err := f.auth.DeleteUser(clientinfo.WithUserID(context.Background(), userID), userID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	want := []string{
+		EventUserRegistered,
+		EventLoggedIn, EventTokenIssued,
+		EventTokenIssued,
+		EventSessionRevoked,
+		EventLoggedIn, EventTokenIssued,
+		EventPasswordChanged, EventUserSessionsRevoked,
+		EventUserDeleted, EventUserSessionsRevoked,
+	}
+	if got := events.types(); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	// RevokeSession only knows the session, so its event has no user.
+	for _, event := range events.events {
+		if (event.UserId != userID && event.Type != EventSessionRevoked) || !event.OccurredAt.Equal(testEpoch) {
+			t.Errorf("event %+v lacks the user or time", event)
+		}
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	events := &fakeEvents{}
+	f := newFixture(t, WithEventStore(events))
+	adminCtx := f.addAdmin(t)
+
+	for range 5 {
+		f.auth.emit(context.Background(), models.Event{Type: EventLoggedIn})
+	}
+
+	tests := []struct {
+		name      string
+		afterSeq  int64
+		limit     int
+		want      []int64
+		wantLimit int
+	}{
+		{name: "from the start", limit: 2, want: []int64{1, 2}, wantLimit: 2},
+		{name: "from a position", afterSeq: 3, limit: 10, want: []int64{4, 5}, wantLimit: 10},
+		{name: "default limit", afterSeq: 4, want: []int64{5}, wantLimit: defaultPageSize},
+		{name: "capped limit", limit: maxPageSize + 1, want: []int64{1, 2, 3, 4, 5}, wantLimit: maxPageSize},
+		{name: "at the end", afterSeq: 5, limit: 10, wantLimit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayed, err := f.auth.ReplayEvents(adminCtx, tt.afterSeq, tt.limit)
+			if err != nil {
+				t.Fatalf("ReplayEvents: %v", err)
+			}
+
+			var seqs []int64
+			for _, event := range replayed {
+				seqs = append(seqs, event.Seq)
+			}
+
+			if !slices.Equal(seqs, tt.want) {
+				t.Fatalf("ReplayEvents() = %v, want %v", seqs, tt.want)
+			}
+
+			if got := events.limits[len(events.limits)-1]; got != tt.wantLimit {
+				t.Fatalf("store limit = %d, want %d", got, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestReplayEventsRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   EventStore
+		caller  string
+		wantErr error
+	}{
+		{name: "not configured", caller: "admin", wantErr: ErrNotConfigured},
+		{name: "not admin", store: &fakeEvents{}, caller: "user", wantErr: ErrPermissionDenied},
+		{name: "anonymous", store: &fakeEvents{}, wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.store != nil {
+				opts = append(opts, WithEventStore(tt.store))
+			}
+
+			f := newFixture(t, opts...)
+			adminCtx := f.addAdmin(t)
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			ctx := context.Background()
+			switch tt.caller {
+			case "admin":
+				ctx = adminCtx
+			case "user":
+				ctx = clientinfo.WithUserID(ctx, int64(user.Id))
+			}
+
+			if _, err := f.auth.ReplayEvents(ctx, 0, 10); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReplayEvents() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// eventUsers saves users and their registration event together.
+type eventUsers struct {
+	*fakeUsers
+	events *fakeEvents
+	saved  int
+}
+
+func (s *eventUsers) SaveUserWithEvent(ctx context.Context, name string, passHash []byte, event models.Event) (int64, error) {
+	userID, err := s.SaveUser(ctx, name, passHash)
+	if err != nil {
+		return 0, err
+	}
+
+	s.saved++
+	event.UserId = userID
+
+	return userID, s.events.AppendEvent(ctx, event)
+}
+
+func TestRegistrationEventIsAtomic(t *testing.T) {
+	events := &fakeEvents{}
+	users := &eventUsers{fakeUsers: newFakeUsers(), events: events}
+	clock := &fakeClock{now: testEpoch.Add(time.Hour)}
+
+	auth := New(discardLogger(), users, users, newFakeApps(), testTokenTTL,
+		WithClock(clock.Now),
+		WithEventStore(events),
+	)
+
+	userID, err := auth.RegisterNewUser(context.Background(), "user@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("RegisterNewUser: %v", err)
+	}
+
+	if users.saved != 1 {
+		t.Fatalf("%d transactional saves, want 1", users.saved)
+	}
+
+	if len(events.events) != 1 {
+		t.Fatalf("events = %v, want only the registration", events.types())
+	}
+
+	if event := events.events[0]; event.Type != EventUserRegistered || event.UserId != userID || !event.OccurredAt.Equal(clock.Now()) {
+		t.Fatalf("registration event = %+v, want user %d at %v", event, userID, clock.Now())
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"time"
+)
+
+var ErrSessionLimitReached = errors.New("session limit reached")
+
+// Built-in eviction policy names, see App.SessionEviction.
+const (
+	EvictionReject = "reject"
+	EvictionOldest = "oldest"
+	EvictionLRU    = "lru"
+)
+
+// EvictionPolicy decides which sessions end when a user already has limit
+// active sessions in an app and starts another one.
+type EvictionPolicy interface {
+	// Victims returns the sessions to revoke to make room for one more, or
+	// ErrSessionLimitReached to refuse the new session instead.
+	Victims(active []*models.Session, limit int) ([]*models.Session, error)
+}
+
+// EvictionPolicyFunc adapts a function to EvictionPolicy.
+type EvictionPolicyFunc func(active []*models.Session, limit int) ([]*models.Session, error)
+
+func (f EvictionPolicyFunc) Victims(active []*models.Session, limit int) ([]*models.Session, error) {
+	return f(active, limit)
+}
+
+// RejectNewSessions refuses new sessions once the limit is reached.
+var RejectNewSessions = EvictionPolicyFunc(func([]*models.Session, int) ([]*models.Session, error) {
+	return nil, ErrSessionLimitReached
+})
+
+// EvictOldestSessions ends the sessions that were created first.
+var EvictOldestSessions = evictBy(func(s *models.Session) time.Time { return s.CreatedAt })
+
+// EvictLeastRecentlyUsedSessions ends the sessions that were idle longest.
+var EvictLeastRecentlyUsedSessions = evictBy(func(s *models.Session) time.Time { return s.LastActivityAt })
+
+// evictBy returns a policy evicting the sessions with the earliest key.
+func evictBy(key func(s *models.Session) time.Time) EvictionPolicy {
+	return EvictionPolicyFunc(func(active []*models.Session, limit int) ([]*models.Session, error) {
+		excess := len(active) - limit + 1
+		if excess <= 0 {
+			return nil, nil
+		}
+
+		sorted := slices.Clone(active)
+		slices.SortStableFunc(sorted, func(a, b *models.Session) int {
+			return key(a).Compare(key(b))
+		})
+
+		return sorted[:excess], nil
+	})
+}
+
+// WithEvictionPolicy registers policy under name, so apps can select it
+// through App.SessionEviction. The built-in policies are "reject",
+// "oldest" and "lru".
+func WithEvictionPolicy(name string, policy EvictionPolicy) Option {
+	return func(auth *Auth) {
+		if auth.evictionPolicies == nil {
+			auth.evictionPolicies = defaultEvictionPolicies()
+		}
+
+		auth.evictionPolicies[name] = policy
+	}
+}
+
+func defaultEvictionPolicies() map[string]EvictionPolicy {
+	return map[string]EvictionPolicy{
+		EvictionReject: RejectNewSessions,
+		EvictionOldest: EvictOldestSessions,
+		EvictionLRU:    EvictLeastRecentlyUsedSessions,
+	}
+}
+
+// evictionPolicy returns the policy app selects, rejecting new sessions by
+// default.
+func (auth *Auth) evictionPolicy(app *models.App) (EvictionPolicy, error) {
+	policies := auth.evictionPolicies
+	if policies == nil {
+		policies = defaultEvictionPolicies()
+	}
+
+	name := app.SessionEviction
+	if name == "" {
+		name = EvictionReject
+	}
+
+	policy, ok := policies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown session eviction policy %q", name)
+	}
+
+	return policy, nil
+}
+
+// enforceSessionLimit makes room for a new session of user in app, applying
+// the app's eviction policy once MaxSessions is reached.
+func (auth *Auth) enforceSessionLimit(ctx context.Context, log *slog.Logger, userID int64, app *models.App) error {
+	if app.MaxSessions <= 0 {
+		return nil
+	}
+
+	now := auth.now()
+
+	active, err := auth.sessionStore.ActiveUserSessions(ctx, userID, app.Id, now)
+	if err != nil {
+		return err
+	}
+
+	if len(active) < app.MaxSessions {
+		return nil
+	}
+
+	policy, err := auth.evictionPolicy(app)
+	if err != nil {
+		return err
+	}
+
+	victims, err := policy.Victims(active, app.MaxSessions)
+	if err != nil {
+		return err
+	}
+
+	for _, victim := range victims {
+		if err := auth.sessionStore.RevokeSession(ctx, victim.Id, now); err != nil {
+			return err
+		}
+
+		log.Info("session evicted", slog.String("sessionID", victim.Id))
+
+		auth.recordRotation(ctx, victim.Id, "", time.Time{}, RotationRevoked)
+		auth.emit(ctx, models.Event{
+			Type:      EventSessionRevoked,
+			UserId:    victim.UserId,
+			AppId:     victim.AppId,
+			SessionId: victim.Id,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+)
+
+func TestSessionEviction(t *testing.T) {
+	evictAll := EvictionPolicyFunc(func(active []*models.Session, _ int) ([]*models.Session, error) {
+		return active, nil
+	})
+
+	tests := []struct {
+		name     string
+		eviction string
+		// wantActive lists the sessions active after the third login, by
+		// login number.
+		wantActive []int
+		wantErr    error
+	}{
+		{name: "default rejects", wantActive: []int{1, 2}, wantErr: ErrSessionLimitReached},
+		{name: "reject", eviction: EvictionReject, wantActive: []int{1, 2}, wantErr: ErrSessionLimitReached},
+		{name: "oldest", eviction: EvictionOldest, wantActive: []int{2, 3}},
+		// The first session was used last, so the second one goes.
+		{name: "least recently used", eviction: EvictionLRU, wantActive: []int{1, 3}},
+		{name: "custom policy", eviction: "all", wantActive: []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t,
+				WithSessionStore(sessions),
+				WithSessionTimeouts(time.Hour, 0),
+				WithEvictionPolicy("all", evictAll),
+			)
+			f.apps.update(testAppID, func(app *models.App) {
+				app.MaxSessions = 2
+				app.SessionEviction = tt.eviction
+			})
+			f.addUser(t, "user@example.com", testPassword)
+
+			first := f.login(t, "user@example.com")
+			f.clock.Advance(time.Minute)
+			second := f.login(t, "user@example.com")
+			f.clock.Advance(time.Minute)
+
+			if _, err := f.auth.ValidateToken(context.Background(), first.Token, testAppID); err != nil {
+				t.Fatalf("ValidateToken(first): %v", err)
+			}
+
+			f.clock.Advance(time.Minute)
+
+			third, err := f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login(third) error = %v, want %v", err, tt.wantErr)
+			}
+
+			ids := []string{first.SessionID, second.SessionID}
+			if third != nil {
+				ids = append(ids, third.SessionID)
+			}
+
+			var active []int
+			for i, id := range ids {
+				if sessions.get(id).RevokedAt.IsZero() {
+					active = append(active, i+1)
+				}
+			}
+
+			if !slices.Equal(active, tt.wantActive) {
+				t.Fatalf("active sessions = %v, want %v", active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestSessionEvictionUnknownPolicy(t *testing.T) {
+	f := newFixture(t, WithSessionStore(newFakeSessions()))
+	f.apps.update(testAppID, func(app *models.App) {
+		app.MaxSessions = 1
+		app.SessionEviction = "missing"
+	})
+	f.addUser(t, "user@example.com", testPassword)
+
+	f.login(t, "user@example.com")
+
+	if _, err := f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID); err == nil {
+		t.Fatal("Login() error = nil, want an error for the unknown policy")
+	}
+}
+
+func TestEvictBy(t *testing.T) {
+	active := []*models.Session{
+		{Id: "a", CreatedAt: testEpoch, LastActivityAt: testEpoch.Add(3 * time.Minute)},
+		{Id: "b", CreatedAt: testEpoch.Add(time.Minute), LastActivityAt: testEpoch.Add(time.Minute)},
+		{Id: "c", CreatedAt: testEpoch.Add(2 * time.Minute), LastActivityAt: testEpoch.Add(2 * time.Minute)},
+	}
+
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		limit  int
+		want   []string
+	}{
+		{name: "oldest at the limit", policy: EvictOldestSessions, limit: 3, want: []string{"a"}},
+		{name: "oldest above the limit", policy: EvictOldestSessions, limit: 2, want: []string{"a", "b"}},
+		{name: "oldest below the limit", policy: EvictOldestSessions, limit: 4},
+		{name: "lru at the limit", policy: EvictLeastRecentlyUsedSessions, limit: 3, want: []string{"b"}},
+		{name: "lru above the limit", policy: EvictLeastRecentlyUsedSessions, limit: 2, want: []string{"b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victims, err := tt.policy.Victims(active, tt.limit)
+			if err != nil {
+				t.Fatalf("Victims: %v", err)
+			}
+
+			var ids []string
+			for _, victim := range victims {
+				ids = append(ids, victim.Id)
+			}
+
+			if !slices.Equal(ids, tt.want) {
+				t.Fatalf("Victims() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
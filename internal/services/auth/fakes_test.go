@@ -0,0 +1,1110 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAppID     int32 = 1
+	testAppSecret       = "test-app-secret"
+	testTokenTTL        = time.Hour
+	testPassword        = "Correct-Horse-42"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// fakeUsers is an in-memory user store.
+type fakeUsers struct {
+	mu     sync.Mutex
+	nextID int32
+	byID   map[int64]*models.User
+	admins map[int64]bool
+	// err, when set, is returned by every lookup.
+	err error
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{
+		byID:   make(map[int64]*models.User),
+		admins: make(map[int64]bool),
+	}
+}
+
+func (s *fakeUsers) add(user models.User) *models.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	user.Id = s.nextID
+	s.byID[int64(user.Id)] = &user
+
+	return &user
+}
+
+func (s *fakeUsers) get(userID int64) *models.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[userID]
+	if !ok {
+		return nil
+	}
+
+	clone := *user
+
+	return &clone
+}
+
+func (s *fakeUsers) findLocked(email string) *models.User {
+	for _, user := range s.byID {
+		if user.Name == email {
+			return user
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeUsers) SaveUser(_ context.Context, name string, passHash []byte) (int64, error) {
+	s.mu.Lock()
+	exists := s.findLocked(name) != nil
+	s.mu.Unlock()
+
+	if exists {
+		return 0, storage.ErrUserExists
+	}
+
+	return int64(s.add(models.User{Name: name, PassHash: passHash}).Id), nil
+}
+
+func (s *fakeUsers) User(_ context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	user := s.findLocked(email)
+	if user == nil {
+		return nil, storage.ErrUserNotFound
+	}
+
+	clone := *user
+
+	return &clone, nil
+}
+
+func (s *fakeUsers) UserByID(_ context.Context, userID int64) (*models.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	user := s.get(userID)
+	if user == nil {
+		return nil, storage.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (s *fakeUsers) IsAdmin(_ context.Context, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[userID]; !ok {
+		return false, storage.ErrUserNotFound
+	}
+
+	return s.admins[userID], nil
+}
+
+func (s *fakeUsers) Admins(context.Context) ([]*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var admins []*models.User
+	for _, id := range slices.Sorted(maps.Keys(s.admins)) {
+		clone := *s.byID[id]
+		admins = append(admins, &clone)
+	}
+
+	return admins, nil
+}
+
+// update applies fn to the user if its version still equals version.
+func (s *fakeUsers) update(userID int64, version int64, fn func(user *models.User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[userID]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+
+	if user.Version != version {
+		return storage.ErrVersionConflict
+	}
+
+	fn(user)
+	user.Version++
+
+	return nil
+}
+
+func (s *fakeUsers) UpdatePassword(_ context.Context, userID int64, passHash []byte, version int64, changedAt time.Time) error {
+	return s.update(userID, version, func(user *models.User) {
+		user.PassHash = passHash
+		user.PasswordChangedAt = changedAt
+	})
+}
+
+func (s *fakeUsers) UpdateProfile(
+	_ context.Context,
+	userID int64,
+	displayName string,
+	metadata map[string]string,
+	version int64,
+	updatedAt time.Time,
+) error {
+	return s.update(userID, version, func(user *models.User) {
+		user.DisplayName = displayName
+		user.Metadata = metadata
+		user.UpdatedAt = updatedAt
+	})
+}
+
+func (s *fakeUsers) MarkForRehash(_ context.Context, userIDs []int64, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range userIDs {
+		if user, ok := s.byID[id]; ok {
+			user.NeedsRehash = true
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeUsers) Rehash(_ context.Context, userID int64, passHash []byte, version int64, _ time.Time) error {
+	return s.update(userID, version, func(user *models.User) {
+		user.PassHash = passHash
+		user.NeedsRehash = false
+	})
+}
+
+func (s *fakeUsers) RehashCounts(context.Context) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending int64
+	for _, user := range s.byID {
+		if user.NeedsRehash {
+			pending++
+		}
+	}
+
+	return pending, 0, nil
+}
+
+func (s *fakeUsers) SoftDeleteUser(_ context.Context, userID int64, deletedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[userID]
+	if !ok || !user.DeletedAt.IsZero() {
+		return storage.ErrUserNotFound
+	}
+
+	user.DeletedAt = deletedAt
+
+	return nil
+}
+
+func (s *fakeUsers) PurgeDeletedUsers(_ context.Context, deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, user := range s.byID {
+		if !user.DeletedAt.IsZero() && user.DeletedAt.Before(deletedBefore) {
+			delete(s.byID, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (s *fakeUsers) DeleteUser(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[userID]; !ok {
+		return storage.ErrUserNotFound
+	}
+
+	delete(s.byID, userID)
+
+	return nil
+}
+
+func (s *fakeUsers) ScanPasswordHashes(ctx context.Context, fn func(hash []byte) error) error {
+	s.mu.Lock()
+	var hashes [][]byte
+	for _, id := range slices.Sorted(maps.Keys(s.byID)) {
+		hashes = append(hashes, s.byID[id].PassHash)
+	}
+	s.mu.Unlock()
+
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fakeApps is an in-memory app store.
+type fakeApps struct {
+	mu   sync.Mutex
+	apps map[int32]*models.App
+}
+
+func newFakeApps(apps ...models.App) *fakeApps {
+	s := &fakeApps{apps: make(map[int32]*models.App)}
+	for _, app := range apps {
+		s.apps[app.Id] = &app
+	}
+
+	return s
+}
+
+func (s *fakeApps) App(_ context.Context, appID int32) (*models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return nil, storage.ErrAppNotFound
+	}
+
+	clone := *app
+
+	return &clone, nil
+}
+
+func (s *fakeApps) SetTokensValidAfter(_ context.Context, appID int32, validAfter time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return storage.ErrAppNotFound
+	}
+
+	app.TokensValidAfter = validAfter
+
+	return nil
+}
+
+func (s *fakeApps) add(app models.App) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apps[app.Id] = &app
+}
+
+// update changes a stored app in place.
+func (s *fakeApps) update(appID int32, fn func(app *models.App)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(s.apps[appID])
+}
+
+// fakeSessions is an in-memory session store.
+type fakeSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*models.Session
+	// users, when set, makes RevokeUserSessions fail for unknown users.
+	users *fakeUsers
+}
+
+func newFakeSessions() *fakeSessions {
+	return &fakeSessions{sessions: make(map[string]*models.Session)}
+}
+
+func (s *fakeSessions) get(sessionID string) *models.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+
+	clone := *session
+
+	return &clone
+}
+
+func (s *fakeSessions) SaveSession(_ context.Context, session models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.Id] = &session
+
+	return nil
+}
+
+func (s *fakeSessions) Session(_ context.Context, sessionID string) (*models.Session, error) {
+	session := s.get(sessionID)
+	if session == nil {
+		return nil, storage.ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (s *fakeSessions) RevokeSession(_ context.Context, sessionID string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return storage.ErrSessionNotFound
+	}
+
+	session.RevokedAt = revokedAt
+
+	return nil
+}
+
+func (s *fakeSessions) TouchSession(_ context.Context, sessionID string, activeAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return storage.ErrSessionNotFound
+	}
+
+	session.LastActivityAt = activeAt
+
+	return nil
+}
+
+func (s *fakeSessions) CountActiveSessions(_ context.Context, now time.Time, activeSince time.Time) (map[int32]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[int32]int64)
+	for _, session := range s.sessions {
+		if session.RevokedAt.IsZero() && session.ExpiresAt.After(now) &&
+			(activeSince.IsZero() || !session.LastActivityAt.Before(activeSince)) {
+			counts[session.AppId]++
+		}
+	}
+
+	return counts, nil
+}
+
+func (s *fakeSessions) RevokeUserSessions(_ context.Context, userID int64) error {
+	if s.users != nil && s.users.get(userID) == nil {
+		return storage.ErrUserNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.UserId == userID && session.RevokedAt.IsZero() {
+			session.RevokedAt = time.Now()
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeSessions) ActiveUserSessions(_ context.Context, userID int64, appID int32, now time.Time) ([]*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var active []*models.Session
+	for _, session := range s.sessions {
+		if session.UserId == userID && session.AppId == appID && session.RevokedAt.IsZero() && session.ExpiresAt.After(now) {
+			clone := *session
+			active = append(active, &clone)
+		}
+	}
+
+	slices.SortFunc(active, func(a, b *models.Session) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return active, nil
+}
+
+// fixture is an Auth wired to in-memory stores and a fake clock.
+type fixture struct {
+	auth  *Auth
+	users *fakeUsers
+	apps  *fakeApps
+	clock *fakeClock
+}
+
+// newFixture returns an Auth with one app, testAppID, and a cheap bcrypt
+// cost. opts are applied after the defaults, so they can replace them.
+func newFixture(t *testing.T, opts ...Option) *fixture {
+	t.Helper()
+
+	f := &fixture{
+		users: newFakeUsers(),
+		apps:  newFakeApps(models.App{Id: testAppID, Name: "test", Secret: testAppSecret}),
+		clock: &fakeClock{now: testEpoch},
+	}
+
+	defaults := []Option{
+		WithClock(f.clock.Now),
+		WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)),
+		WithUserUpdater(f.users),
+	}
+
+	f.auth = New(discardLogger(), f.users, f.users, f.apps, testTokenTTL, append(defaults, opts...)...)
+
+	return f
+}
+
+// addUser stores a user with the given email and password, hashed the way
+// the fixture's Auth hashes new passwords.
+func (f *fixture) addUser(t *testing.T, email, password string) *models.User {
+	t.Helper()
+
+	var passHash []byte
+	if password != "" {
+		var err error
+
+		passHash, err = f.auth.hashPassword(context.Background(), password)
+		if err != nil {
+			t.Fatalf("hashPassword: %v", err)
+		}
+	}
+
+	return f.users.add(models.User{Name: email, PassHash: passHash})
+}
+
+// addAdmin stores an admin user and returns a context authenticated as it.
+func (f *fixture) addAdmin(t *testing.T) context.Context {
+	t.Helper()
+
+	admin := f.addUser(t, "admin@example.com", testPassword)
+
+	f.users.mu.Lock()
+	f.users.admins[int64(admin.Id)] = true
+	f.users.mu.Unlock()
+
+	return clientinfo.WithUserID(context.Background(), int64(admin.Id))
+}
+
+// login logs in as email with testPassword to testAppID.
+func (f *fixture) login(t *testing.T, email string, opts ...LoginOption) *LoginResult {
+	t.Helper()
+
+	result, err := f.auth.Login(context.Background(), email, testPassword, testAppID, opts...)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	return result
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeAPIKeys is an in-memory API key store. Every touch is also sent on
+// touched, if it is set.
+type fakeAPIKeys struct {
+	mu      sync.Mutex
+	keys    map[string]*models.APIKey
+	touched chan string
+}
+
+func newFakeAPIKeys(keys ...models.APIKey) *fakeAPIKeys {
+	s := &fakeAPIKeys{keys: make(map[string]*models.APIKey)}
+	for _, key := range keys {
+		s.keys[key.Id] = &key
+	}
+
+	return s
+}
+
+func (s *fakeAPIKeys) get(keyID string) *models.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[keyID]
+	if !ok {
+		return nil
+	}
+
+	clone := *key
+
+	return &clone
+}
+
+func (s *fakeAPIKeys) APIKey(_ context.Context, keyID string) (*models.APIKey, error) {
+	key := s.get(keyID)
+	if key == nil {
+		return nil, storage.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (s *fakeAPIKeys) APIKeys(_ context.Context, userID int64) ([]models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []models.APIKey
+	for _, id := range slices.Sorted(maps.Keys(s.keys)) {
+		if key := s.keys[id]; key.UserId == userID {
+			keys = append(keys, *key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *fakeAPIKeys) RevokeAPIKey(_ context.Context, userID int64, keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[keyID]
+	if !ok || key.UserId != userID {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	delete(s.keys, keyID)
+
+	return nil
+}
+
+func (s *fakeAPIKeys) TouchAPIKey(_ context.Context, keyID string, usedAt time.Time) error {
+	s.mu.Lock()
+	key, ok := s.keys[keyID]
+	if ok {
+		key.LastUsedAt = usedAt
+	}
+	s.mu.Unlock()
+
+	if s.touched != nil {
+		s.touched <- keyID
+	}
+
+	if !ok {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (s *fakeAPIKeys) APIKeysUnusedSince(_ context.Context, before time.Time) ([]models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []models.APIKey
+	for _, id := range slices.Sorted(maps.Keys(s.keys)) {
+		key := s.keys[id]
+
+		lastUsed := key.LastUsedAt
+		if lastUsed.IsZero() {
+			lastUsed = key.CreatedAt
+		}
+
+		if lastUsed.Before(before) {
+			keys = append(keys, *key)
+		}
+	}
+
+	return keys, nil
+}
+
+// fakeActionTokens is an in-memory action token store.
+type fakeActionTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*models.ActionToken
+}
+
+func newFakeActionTokens() *fakeActionTokens {
+	return &fakeActionTokens{tokens: make(map[string]*models.ActionToken)}
+}
+
+func (s *fakeActionTokens) SaveActionToken(_ context.Context, token models.ActionToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[string(token.Hash)] = &token
+
+	return nil
+}
+
+func (s *fakeActionTokens) ActionToken(_ context.Context, hash []byte) (*models.ActionToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[string(hash)]
+	if !ok {
+		return nil, storage.ErrActionTokenNotFound
+	}
+
+	clone := *token
+
+	return &clone, nil
+}
+
+func (s *fakeActionTokens) MarkActionTokenUsed(_ context.Context, hash []byte, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[string(hash)]
+	if !ok {
+		return storage.ErrActionTokenNotFound
+	}
+
+	if !token.UsedAt.IsZero() {
+		return storage.ErrActionTokenUsed
+	}
+
+	token.UsedAt = usedAt
+
+	return nil
+}
+
+// fakeMetrics records the reported metrics.
+type fakeMetrics struct {
+	mu             sync.Mutex
+	activeSessions map[int32]int64
+	tokenVersions  map[int]int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{
+		activeSessions: make(map[int32]int64),
+		tokenVersions:  make(map[int]int),
+	}
+}
+
+func (m *fakeMetrics) SetActiveSessions(appID int32, count int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.activeSessions[appID] = count
+}
+
+func (m *fakeMetrics) IncTokenVersion(_ int32, version int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokenVersions[version]++
+}
+
+// fakeAudit is an in-memory audit log.
+type fakeAudit struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+	// limits records the limit of every AuditEvents call.
+	limits []int
+}
+
+func (s *fakeAudit) AppendAuditEvent(_ context.Context, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.Seq = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func (s *fakeAudit) AuditEvents(_ context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits = append(s.limits, limit)
+
+	var events []models.AuditEvent
+	for _, event := range s.events {
+		if event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *fakeAudit) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var types []string
+	for _, event := range s.events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+// fakeRotations is an in-memory rotation store.
+type fakeRotations struct {
+	mu      sync.Mutex
+	records []models.RotationRecord
+}
+
+func (s *fakeRotations) AppendRotationRecord(_ context.Context, record models.RotationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+
+	return nil
+}
+
+func (s *fakeRotations) RotationRecords(_ context.Context, sessionID string) ([]models.RotationRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []models.RotationRecord
+	for _, record := range s.records {
+		if record.SessionId == sessionID {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// fakeInvitations is an in-memory invitation store that saves accepted
+// users to users.
+type fakeInvitations struct {
+	mu          sync.Mutex
+	invitations map[string]*models.Invitation
+	users       *fakeUsers
+}
+
+func newFakeInvitations(users *fakeUsers) *fakeInvitations {
+	return &fakeInvitations{invitations: make(map[string]*models.Invitation), users: users}
+}
+
+func (s *fakeInvitations) only() *models.Invitation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, invitation := range s.invitations {
+		clone := *invitation
+
+		return &clone
+	}
+
+	return nil
+}
+
+func (s *fakeInvitations) SaveInvitation(_ context.Context, invitation models.Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invitations[string(invitation.Hash)] = &invitation
+
+	return nil
+}
+
+func (s *fakeInvitations) Invitation(_ context.Context, hash []byte) (*models.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.invitations[string(hash)]
+	if !ok {
+		return nil, storage.ErrInvitationNotFound
+	}
+
+	clone := *invitation
+
+	return &clone, nil
+}
+
+func (s *fakeInvitations) ConsumeInvitation(_ context.Context, hash []byte, passHash []byte, usedAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.invitations[string(hash)]
+	if !ok {
+		return 0, storage.ErrInvitationNotFound
+	}
+
+	if !invitation.UsedAt.IsZero() {
+		return 0, storage.ErrInvitationUsed
+	}
+
+	s.users.mu.Lock()
+	exists := s.users.findLocked(invitation.Email) != nil
+	s.users.mu.Unlock()
+
+	if exists {
+		return 0, storage.ErrUserExists
+	}
+
+	user := s.users.add(models.User{
+		Name:          invitation.Email,
+		PassHash:      passHash,
+		Roles:         invitation.Roles,
+		EmailVerified: true,
+	})
+	invitation.UsedAt = usedAt
+
+	return int64(user.Id), nil
+}
+
+// fakeResolver answers DNS lookups from fixed records. Unknown names are
+// not found, unless err is set.
+type fakeResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+	err   error
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if mx, ok := r.mx[name]; ok {
+		return mx, nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// fakeIdempotency is an in-memory idempotency store.
+type fakeIdempotency struct {
+	mu     sync.Mutex
+	logins map[string]*models.IdempotentLogin
+	// err, when set, is returned by every lookup.
+	err error
+}
+
+func newFakeIdempotency() *fakeIdempotency {
+	return &fakeIdempotency{logins: make(map[string]*models.IdempotentLogin)}
+}
+
+func (s *fakeIdempotency) SaveIdempotentLogin(_ context.Context, login models.IdempotentLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logins[string(login.KeyHash)] = &login
+
+	return nil
+}
+
+func (s *fakeIdempotency) IdempotentLogin(_ context.Context, keyHash []byte) (*models.IdempotentLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	login, ok := s.logins[string(keyHash)]
+	if !ok {
+		return nil, storage.ErrIdempotencyKeyNotFound
+	}
+
+	clone := *login
+
+	return &clone, nil
+}
+
+// fakeEvents is an in-memory event log.
+type fakeEvents struct {
+	mu     sync.Mutex
+	events []models.Event
+	// limits records the limit of every Events call.
+	limits []int
+}
+
+func (s *fakeEvents) AppendEvent(_ context.Context, event models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.Seq = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func (s *fakeEvents) Events(_ context.Context, afterSeq int64, limit int) ([]models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits = append(s.limits, limit)
+
+	var events []models.Event
+	for _, event := range s.events {
+		if event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *fakeEvents) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var types []string
+	for _, event := range s.events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+// fakePeppers is an in-memory pepper store, shared by the Auth instances of
+// a test like a secrets manager.
+type fakePeppers struct {
+	mu      sync.Mutex
+	peppers map[int][]byte
+	// saveErr, if set, fails every SavePepper.
+	saveErr error
+}
+
+func newFakePeppers() *fakePeppers {
+	return &fakePeppers{peppers: make(map[int][]byte)}
+}
+
+func (s *fakePeppers) SavePepper(_ context.Context, version int, pepper []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+
+	if _, ok := s.peppers[version]; ok {
+		return storage.ErrPepperExists
+	}
+
+	s.peppers[version] = bytes.Clone(pepper)
+
+	return nil
+}
+
+func (s *fakePeppers) Pepper(_ context.Context, version int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pepper, ok := s.peppers[version]
+	if !ok {
+		return nil, storage.ErrPepperNotFound
+	}
+
+	return bytes.Clone(pepper), nil
+}
+
+func (s *fakePeppers) Peppers(context.Context) (map[int][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.peppers), nil
+}
+
+// fakeRevocations is an in-memory revoked token store.
+type fakeRevocations struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	// lookups counts IsTokenRevoked calls.
+	lookups int
+}
+
+func newFakeRevocations() *fakeRevocations {
+	return &fakeRevocations{revoked: make(map[string]time.Time)}
+}
+
+func (s *fakeRevocations) RevokeToken(_ context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[tokenID] = expiresAt
+
+	return nil
+}
+
+func (s *fakeRevocations) IsTokenRevoked(_ context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lookups++
+	_, ok := s.revoked[tokenID]
+
+	return ok, nil
+}
+
+func (s *fakeRevocations) RevokedTokenIDs(_ context.Context, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, expiresAt := range s.revoked {
+		if expiresAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// PasswordHasher hashes and verifies passwords.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	// Compare returns nil if password matches hash.
+	Compare(hash []byte, password string) error
+	// NeedsRehash reports whether hash was made with outdated parameters.
+	NeedsRehash(hash []byte) bool
+}
+
+// HashEncoding is the form in which hashes are stored.
+type HashEncoding int
+
+const (
+	// HashEncodingRaw stores the bcrypt output as is, e.g. "$2a$10$...".
+	// It is the default.
+	HashEncodingRaw HashEncoding = iota
+	// HashEncodingBase64 stores the bcrypt output base64-encoded, for
+	// systems that expect hashes in that form.
+	HashEncodingBase64
+)
+
+// BcryptHasher is the default PasswordHasher. Compare and NeedsRehash
+// accept hashes in either encoding, so the encoding can change without
+// invalidating stored hashes.
+type BcryptHasher struct {
+	cost     int
+	encoding HashEncoding
+}
+
+// BcryptOption configures a BcryptHasher.
+type BcryptOption func(h *BcryptHasher)
+
+// BcryptEncoding sets the encoding of new hashes.
+func BcryptEncoding(encoding HashEncoding) BcryptOption {
+	return func(h *BcryptHasher) {
+		h.encoding = encoding
+	}
+}
+
+// NewBcryptHasher returns a bcrypt hasher using cost for new hashes.
+func NewBcryptHasher(cost int, opts ...BcryptOption) *BcryptHasher {
+	h := &BcryptHasher{cost: cost}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *BcryptHasher) Hash(password string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.encoding == HashEncodingBase64 {
+		return []byte(base64.StdEncoding.EncodeToString(hash)), nil
+	}
+
+	return hash, nil
+}
+
+func (h *BcryptHasher) Compare(hash []byte, password string) error {
+	return bcrypt.CompareHashAndPassword(decodeBcrypt(hash), []byte(password))
+}
+
+func (h *BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(decodeBcrypt(hash))
+
+	return err != nil || cost != h.cost
+}
+
+// decodeBcrypt returns the raw bcrypt hash. Raw hashes start with "$",
+// which never begins a base64 string.
+func decodeBcrypt(hash []byte) []byte {
+	if len(hash) == 0 || hash[0] == '$' {
+		return hash
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(hash))
+	if err != nil {
+		return hash
+	}
+
+	return decoded
+}
+
+var ErrTimeout = errors.New("timed out waiting for a password hashing slot")
+
+// WithHashConcurrency caps the password hash and compare operations running
+// at once to n. Further callers wait for a slot until their context ends,
+// then fail with ErrTimeout. Zero, the default, means no limit.
+func WithHashConcurrency(n int) Option {
+	return func(auth *Auth) {
+		if n > 0 {
+			auth.hashSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// acquireHashSlot waits for a free hashing slot. The returned func frees it.
+func (auth *Auth) acquireHashSlot(ctx context.Context) (func(), error) {
+	if auth.hashSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case auth.hashSlots <- struct{}{}:
+		return func() { <-auth.hashSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	}
+}
+
+// hashPassword hashes password with the current pepper once a hashing slot
+// is free.
+func (auth *Auth) hashPassword(ctx context.Context, password string) ([]byte, error) {
+	release, err := auth.acquireHashSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	version, pepper := auth.currentPepper()
+
+	hash, err := auth.hasher.Hash(applyPepper(pepper, password))
+	if err != nil {
+		return nil, err
+	}
+
+	return addPepperVersion(version, hash), nil
+}
+
+// comparePassword checks password against a stored hash, using the pepper
+// version it was made with, once a hashing slot is free.
+func (auth *Auth) comparePassword(ctx context.Context, stored []byte, password string) error {
+	version, hash, err := splitPepperVersion(stored)
+	if err != nil {
+		return err
+	}
+
+	pepper, err := auth.pepper(ctx, version)
+	if err != nil {
+		auth.log.Error("failed to get pepper",
+			slog.Int("version", version),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return err
+	}
+
+	release, err := auth.acquireHashSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return auth.hasher.Compare(hash, applyPepper(pepper, password))
+}
+
+// needsRehash reports whether a stored hash should be upgraded, because of
+// outdated hasher parameters or an old pepper.
+func (auth *Auth) needsRehash(stored []byte) bool {
+	version, hash, err := splitPepperVersion(stored)
+	if err != nil {
+		return true
+	}
+
+	current, _ := auth.currentPepper()
+
+	return version != current || auth.hasher.NeedsRehash(hash)
+}
+
+// PasswordHashScanner streams stored password hashes, so all users need not
+// be loaded at once.
+type PasswordHashScanner interface {
+	// ScanPasswordHashes calls fn with every stored hash, stopping at the
+	// first error fn returns.
+	ScanPasswordHashes(ctx context.Context, fn func(hash []byte) error) error
+}
+
+// WithPasswordHashScanner enables HashCostDistribution.
+func WithPasswordHashScanner(scanner PasswordHashScanner) Option {
+	return func(auth *Auth) {
+		auth.hashScanner = scanner
+	}
+}
+
+// WithPasswordHasher replaces the default bcrypt hasher.
+func WithPasswordHasher(hasher PasswordHasher) Option {
+	return func(auth *Auth) {
+		auth.hasher = hasher
+	}
+}
+
+// RehashReport counts users flagged by MarkForRehash.
+type RehashReport struct {
+	Pending   int64
+	Completed int64
+}
+
+// MarkForRehash flags users so their password hash is upgraded to the
+// current hasher settings on their next successful login. The caller must
+// be an admin.
+func (auth *Auth) MarkForRehash(ctx context.Context, userIDs []int64) error {
+	const op = "auth.MarkForRehash"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("users", len(userIDs)),
+	)
+
+	if auth.userUpdater == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.userUpdater.MarkForRehash(ctx, userIDs, auth.now()); err != nil {
+		log.Error("failed to mark users for rehash", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("users marked for rehash")
+
+	return nil
+}
+
+// RehashStatus reports pending and completed rehashes. The caller must be
+// an admin.
+func (auth *Auth) RehashStatus(ctx context.Context) (RehashReport, error) {
+	const op = "auth.RehashStatus"
+
+	if auth.userUpdater == nil {
+		return RehashReport{}, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return RehashReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pending, completed, err := auth.userUpdater.RehashCounts(ctx)
+	if err != nil {
+		auth.log.Error("failed to count rehashes",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return RehashReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return RehashReport{Pending: pending, Completed: completed}, nil
+}
+
+// rehashIfNeeded upgrades the user's hash after a successful login when the
+// user was flagged or the hash is outdated, including hashes made with an
+// old pepper. Failures are only logged, the login itself already succeeded.
+func (auth *Auth) rehashIfNeeded(ctx context.Context, log *slog.Logger, user *models.User, password string) {
+	if auth.userUpdater == nil || !user.NeedsRehash && !auth.needsRehash(user.PassHash) {
+		return
+	}
+
+	passHash, err := auth.hashPassword(ctx, password)
+	if err != nil {
+		log.Error("failed to rehash password", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return
+	}
+
+	err = auth.userUpdater.Rehash(ctx, int64(user.Id), passHash, user.Version, auth.now())
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			log.Warn("user changed during rehash, skipping")
+
+			return
+		}
+
+		log.Error("failed to store rehashed password", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
+// HashCostDistribution counts users per bcrypt cost of their stored hash,
+// to plan cost migrations. Accounts without a password are left out and
+// hashes that aren't valid bcrypt are counted under cost 0. The caller must
+// be an admin.
+func (auth *Auth) HashCostDistribution(ctx context.Context) (map[int]int64, error) {
+	const op = "auth.HashCostDistribution"
+
+	if auth.hashScanner == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	distribution := make(map[int]int64)
+
+	err := auth.hashScanner.ScanPasswordHashes(ctx, func(hash []byte) error {
+		if len(hash) == 0 {
+			return nil
+		}
+
+		_, hash, err := splitPepperVersion(hash)
+		if err != nil {
+			distribution[0]++
+
+			return ctx.Err()
+		}
+
+		cost, err := bcrypt.Cost(decodeBcrypt(hash))
+		if err != nil {
+			cost = 0
+		}
+
+		distribution[cost]++
+
+		return ctx.Err()
+	})
+	if err != nil {
+		auth.log.Error("failed to scan password hashes",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return distribution, nil
+}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"maps"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestMarkForRehash(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin       bool
+		wantErr     error
+		wantPending int64
+	}{
+		{name: "admin", admin: true, wantPending: 1},
+		{name: "not admin", wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			adminCtx := f.addAdmin(t)
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			ctx := clientinfo.WithUserID(context.Background(), int64(user.Id))
+			if tt.admin {
+				ctx = adminCtx
+			}
+
+			if err := f.auth.MarkForRehash(ctx, []int64{int64(user.Id)}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MarkForRehash() error = %v, want %v", err, tt.wantErr)
+			}
+
+			report, err := f.auth.RehashStatus(adminCtx)
+			if err != nil {
+				t.Fatalf("RehashStatus: %v", err)
+			}
+
+			if report.Pending != tt.wantPending {
+				t.Fatalf("Pending = %d, want %d", report.Pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestLoginRehashes(t *testing.T) {
+	tests := []struct {
+		name string
+		// storedCost is the bcrypt cost of the stored hash.
+		storedCost int
+		flagged    bool
+		wantRehash bool
+	}{
+		{name: "current hash", storedCost: bcrypt.MinCost},
+		{name: "flagged", storedCost: bcrypt.MinCost, flagged: true, wantRehash: true},
+		{name: "outdated cost", storedCost: bcrypt.MinCost + 1, wantRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			adminCtx := f.addAdmin(t)
+
+			hash, err := NewBcryptHasher(tt.storedCost).Hash(testPassword)
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			user := f.addUser(t, "user@example.com", "")
+			f.users.byID[int64(user.Id)].PassHash = hash
+
+			if tt.flagged {
+				if err := f.auth.MarkForRehash(adminCtx, []int64{int64(user.Id)}); err != nil {
+					t.Fatalf("MarkForRehash: %v", err)
+				}
+			}
+
+			f.login(t, "user@example.com")
+
+			stored := f.users.get(int64(user.Id))
+			if rehashed := string(stored.PassHash) != string(hash); rehashed != tt.wantRehash {
+				t.Fatalf("rehashed = %v, want %v", rehashed, tt.wantRehash)
+			}
+
+			if stored.NeedsRehash {
+				t.Fatal("NeedsRehash still set after login")
+			}
+
+			if cost, _ := bcrypt.Cost(stored.PassHash); cost != bcrypt.MinCost {
+				t.Fatalf("stored cost = %d, want %d", cost, bcrypt.MinCost)
+			}
+
+			f.login(t, "user@example.com")
+		})
+	}
+}
+
+func TestRehashNotConfigured(t *testing.T) {
+	f := newFixture(t, WithUserUpdater(nil))
+	ctx := f.addAdmin(t)
+
+	if err := f.auth.MarkForRehash(ctx, []int64{1}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("MarkForRehash() error = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if _, err := f.auth.RehashStatus(ctx); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("RehashStatus() error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestBcryptHasherEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding HashEncoding
+		wantRaw  bool
+	}{
+		{name: "raw", encoding: HashEncodingRaw, wantRaw: true},
+		{name: "base64", encoding: HashEncodingBase64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := NewBcryptHasher(bcrypt.MinCost, BcryptEncoding(tt.encoding))
+
+			hash, err := hasher.Hash(testPassword)
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			if raw := bytes.HasPrefix(hash, []byte("$2")); raw != tt.wantRaw {
+				t.Fatalf("Hash() = %q, raw %v, want raw %v", hash, raw, tt.wantRaw)
+			}
+
+			// Hashers read either encoding, whatever they write.
+			for _, reader := range []*BcryptHasher{
+				NewBcryptHasher(bcrypt.MinCost),
+				NewBcryptHasher(bcrypt.MinCost, BcryptEncoding(HashEncodingBase64)),
+			} {
+				if err := reader.Compare(hash, testPassword); err != nil {
+					t.Errorf("Compare(%v) error = %v, want nil", reader.encoding, err)
+				}
+
+				if err := reader.Compare(hash, "wrong"); err == nil {
+					t.Errorf("Compare(%v, wrong password) error = nil, want an error", reader.encoding)
+				}
+
+				if reader.NeedsRehash(hash) {
+					t.Errorf("NeedsRehash(%v) = true for a hash of the current cost", reader.encoding)
+				}
+			}
+
+			if !NewBcryptHasher(bcrypt.MinCost + 1).NeedsRehash(hash) {
+				t.Error("NeedsRehash() = false for a hash of an older cost")
+			}
+		})
+	}
+}
+
+func TestHashConcurrency(t *testing.T) {
+	f := newFixture(t, WithHashConcurrency(1))
+	f.addUser(t, "user@example.com", testPassword)
+
+	// Hold the only slot, as a slow login would.
+	release, err := f.auth.acquireHashSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquireHashSlot: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Login(no free slot) error = %v, want %v", err, ErrTimeout)
+	}
+
+	if _, err := f.auth.RegisterNewUser(ctx, "new@example.com", testPassword); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("RegisterNewUser(no free slot) error = %v, want %v", err, ErrTimeout)
+	}
+
+	release()
+
+	f.login(t, "user@example.com")
+}
+
+func TestHashConcurrencyUnlimited(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		f := newFixture(t, WithHashConcurrency(n))
+
+		if f.auth.hashSlots != nil {
+			t.Errorf("WithHashConcurrency(%d) limits hashing, want no limit", n)
+		}
+	}
+}
+
+func TestHashCostDistribution(t *testing.T) {
+	f := newFixture(t)
+	WithPasswordHashScanner(f.users)(f.auth)
+
+	adminCtx := f.addAdmin(t)
+	f.addUser(t, "min@example.com", testPassword)
+	f.addUser(t, "social@example.com", "")
+
+	for _, hasher := range []*BcryptHasher{
+		NewBcryptHasher(bcrypt.MinCost + 1),
+		NewBcryptHasher(bcrypt.MinCost+1, BcryptEncoding(HashEncodingBase64)),
+	} {
+		hash, err := hasher.Hash(testPassword)
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+
+		f.users.add(models.User{Name: "imported@example.com", PassHash: hash})
+	}
+
+	f.users.add(models.User{Name: "broken@example.com", PassHash: []byte("not-a-hash")})
+
+	got, err := f.auth.HashCostDistribution(adminCtx)
+	if err != nil {
+		t.Fatalf("HashCostDistribution: %v", err)
+	}
+
+	// The admin and min users have the fixture's cost.
+	want := map[int]int64{bcrypt.MinCost: 2, bcrypt.MinCost + 1: 2, 0: 1}
+	if !maps.Equal(got, want) {
+		t.Fatalf("HashCostDistribution() = %v, want %v", got, want)
+	}
+}
+
+func TestHashCostDistributionRejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		unconfigured bool
+		anonymous    bool
+		wantErr      error
+	}{
+		{name: "not configured", unconfigured: true, wantErr: ErrNotConfigured},
+		{name: "not admin", anonymous: true, wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if !tt.unconfigured {
+				WithPasswordHashScanner(f.users)(f.auth)
+			}
+
+			ctx := f.addAdmin(t)
+			if tt.anonymous {
+				ctx = context.Background()
+			}
+
+			if _, err := f.auth.HashCostDistribution(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HashCostDistribution() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
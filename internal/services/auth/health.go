@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrUnhealthy = errors.New("service is unhealthy")
+
+// Pinger is implemented by dependencies that can report their health.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthStatus orders from best to worst.
+type HealthStatus int
+
+const (
+	HealthOK HealthStatus = iota
+	// HealthUnknown is reported for dependencies that don't implement Pinger.
+	HealthUnknown
+	HealthDown
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthOK:
+		return "ok"
+	case HealthUnknown:
+		return "unknown"
+	case HealthDown:
+		return "down"
+	}
+
+	return fmt.Sprintf("HealthStatus(%d)", int(s))
+}
+
+// ComponentHealth is the health of one dependency.
+type ComponentHealth struct {
+	Name    string
+	Status  HealthStatus
+	Latency time.Duration
+	Error   string
+}
+
+// HealthReport lists the health of every configured dependency. Status is
+// the worst status among them.
+type HealthReport struct {
+	Status     HealthStatus
+	Components []ComponentHealth
+}
+
+// HealthDetails pings every configured dependency concurrently.
+func (auth *Auth) HealthDetails(ctx context.Context) HealthReport {
+	deps := auth.healthDependencies()
+
+	report := HealthReport{Components: make([]ComponentHealth, len(deps))}
+
+	var wg sync.WaitGroup
+
+	for i, dep := range deps {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			report.Components[i] = auth.componentHealth(ctx, dep.name, dep.value)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, component := range report.Components {
+		report.Status = max(report.Status, component.Status)
+	}
+
+	return report
+}
+
+// HealthCheck returns ErrUnhealthy if any dependency is down.
+func (auth *Auth) HealthCheck(ctx context.Context) error {
+	const op = "auth.HealthCheck"
+
+	report := auth.HealthDetails(ctx)
+
+	var errs []error
+
+	for _, component := range report.Components {
+		if component.Status == HealthDown {
+			errs = append(errs, fmt.Errorf("%s: %s", component.Name, component.Error))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %w: %w", op, ErrUnhealthy, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+type healthDependency struct {
+	name  string
+	value any
+}
+
+func (auth *Auth) healthDependencies() []healthDependency {
+	deps := []healthDependency{
+		{name: "user store", value: auth.userProvider},
+		{name: "app store", value: auth.appProvider},
+	}
+
+	if auth.sessionStore != nil {
+		deps = append(deps, healthDependency{name: "session store", value: auth.sessionStore})
+	}
+
+	if auth.revokedTokens != nil {
+		deps = append(deps, healthDependency{name: "revocation store", value: auth.revokedTokens})
+	}
+
+	if auth.verification != nil {
+		deps = append(deps, healthDependency{name: "notifier", value: auth.verification.notifier})
+	}
+
+	return deps
+}
+
+func (auth *Auth) componentHealth(ctx context.Context, name string, dep any) ComponentHealth {
+	component := ComponentHealth{Name: name}
+
+	pinger, ok := dep.(Pinger)
+	if !ok {
+		component.Status = HealthUnknown
+
+		return component
+	}
+
+	start := time.Now()
+	err := pinger.Ping(ctx)
+	component.Latency = time.Since(start)
+
+	if err != nil {
+		component.Status = HealthDown
+		component.Error = err.Error()
+	}
+
+	return component
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// pingingUsers is a user store that reports err on Ping.
+type pingingUsers struct {
+	*fakeUsers
+	err error
+}
+
+func (p pingingUsers) Ping(context.Context) error {
+	return p.err
+}
+
+// pingingSessions is a session store that reports err on Ping.
+type pingingSessions struct {
+	*fakeSessions
+	err error
+}
+
+func (p pingingSessions) Ping(context.Context) error {
+	return p.err
+}
+
+func TestHealthDetails(t *testing.T) {
+	storeDown := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		users     UserProvider
+		opts      []Option
+		want      map[string]HealthStatus
+		wantWorst HealthStatus
+	}{
+		{
+			name:      "without pingers",
+			users:     newFakeUsers(),
+			want:      map[string]HealthStatus{"user store": HealthUnknown, "app store": HealthUnknown},
+			wantWorst: HealthUnknown,
+		},
+		{
+			name:      "healthy user store",
+			users:     pingingUsers{fakeUsers: newFakeUsers()},
+			want:      map[string]HealthStatus{"user store": HealthOK, "app store": HealthUnknown},
+			wantWorst: HealthUnknown,
+		},
+		{
+			name:      "session store down",
+			users:     pingingUsers{fakeUsers: newFakeUsers()},
+			opts:      []Option{WithSessionStore(pingingSessions{fakeSessions: newFakeSessions(), err: storeDown})},
+			want:      map[string]HealthStatus{"user store": HealthOK, "app store": HealthUnknown, "session store": HealthDown},
+			wantWorst: HealthDown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := New(discardLogger(), newFakeUsers(), tt.users, newFakeApps(), testTokenTTL, tt.opts...)
+
+			report := auth.HealthDetails(context.Background())
+
+			if report.Status != tt.wantWorst {
+				t.Errorf("Status = %s, want %s", report.Status, tt.wantWorst)
+			}
+
+			if len(report.Components) != len(tt.want) {
+				t.Fatalf("Components = %+v, want %v", report.Components, tt.want)
+			}
+
+			for _, component := range report.Components {
+				want, ok := tt.want[component.Name]
+				if !ok || component.Status != want {
+					t.Errorf("%s = %s, want %s", component.Name, component.Status, want)
+				}
+
+				if (component.Status == HealthDown) != (component.Error != "") {
+					t.Errorf("%s: Error = %q with status %s", component.Name, component.Error, component.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		pingErr error
+		wantErr error
+	}{
+		{name: "healthy"},
+		{name: "user store down", pingErr: errors.New("timeout"), wantErr: ErrUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := pingingUsers{fakeUsers: newFakeUsers(), err: tt.pingErr}
+			auth := New(discardLogger(), users, users, newFakeApps(), testTokenTTL)
+
+			if err := auth.HealthCheck(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HealthCheck() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
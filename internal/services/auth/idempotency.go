@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+const defaultIdempotencyWindow = 5 * time.Minute
+
+var errInvalidSealedTokens = errors.New("sealed tokens are too short")
+
+type IdempotencyStore interface {
+	SaveIdempotentLogin(ctx context.Context, login models.IdempotentLogin) error
+	// IdempotentLogin returns storage.ErrIdempotencyKeyNotFound for unknown
+	// or expired keys.
+	IdempotentLogin(ctx context.Context, keyHash []byte) (*models.IdempotentLogin, error)
+}
+
+// WithIdempotentLogins lets Login calls with LoginWithIdempotencyKey return
+// the token of an earlier login with the same key made within window,
+// instead of starting another session.
+func WithIdempotentLogins(store IdempotencyStore, window time.Duration) Option {
+	return func(auth *Auth) {
+		if window <= 0 {
+			window = defaultIdempotencyWindow
+		}
+
+		auth.idempotencyStore = store
+		auth.idempotencyWindow = window
+	}
+}
+
+// LoginWithIdempotencyKey makes a retried Login with the same key return the
+// previously issued token, see WithIdempotentLogins. The key is only
+// honoured after the password has been checked, and is scoped to the user
+// and app, so it can't be used to obtain another user's token. The stored
+// token is encrypted with a key derived from key, so key should be random,
+// like a UUID.
+func LoginWithIdempotencyKey(key string) LoginOption {
+	return func(options *loginOptions) {
+		options.idempotencyKey = key
+	}
+}
+
+// issueIdempotentLogin returns the stored result for the login's
+// idempotency key if there is one and its session is still active,
+// otherwise issues a new login and stores it. Concurrent first attempts may
+// each issue a token, the last one stored is returned to later retries.
+func (auth *Auth) issueIdempotentLogin(
+	ctx context.Context,
+	log *slog.Logger,
+	user *models.User,
+	app *models.App,
+	options loginOptions,
+) (*LoginResult, error) {
+	if auth.idempotencyStore == nil || options.idempotencyKey == "" {
+		return auth.issueLogin(ctx, log, user, app, options)
+	}
+
+	scopedKey := strconv.Itoa(int(user.Id)) + ":" + strconv.Itoa(int(app.Id)) + ":" + options.idempotencyKey
+	keyHash := hashToken(scopedKey)
+
+	stored, err := auth.idempotencyStore.IdempotentLogin(ctx, keyHash)
+	switch {
+	case err == nil && auth.now().Before(stored.ExpiresAt):
+		result, err := auth.replayIdempotentLogin(ctx, log, scopedKey, stored)
+		if err != nil {
+			return nil, err
+		}
+
+		if result != nil {
+			log.Info("returning token of idempotent login")
+
+			return result, nil
+		}
+	case err != nil && !errors.Is(err, storage.ErrIdempotencyKeyNotFound):
+		log.Error("failed to get idempotent login", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	result, err := auth.issueLogin(ctx, log, user, app, options)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := sealIdempotentTokens(auth.random, scopedKey, idempotentTokens{Token: result.Token, CSRFToken: result.CSRFToken})
+	if err != nil {
+		// The login itself succeeded, a retry just gets a new token.
+		log.Error("failed to seal idempotent login", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return result, nil
+	}
+
+	expiresAt := auth.now().Add(auth.idempotencyWindow)
+	if result.ExpiresAt.Before(expiresAt) {
+		expiresAt = result.ExpiresAt
+	}
+
+	err = auth.idempotencyStore.SaveIdempotentLogin(ctx, models.IdempotentLogin{
+		KeyHash:          keyHash,
+		SealedTokens:     sealed,
+		TokenExpiresAt:   result.ExpiresAt,
+		SessionId:        result.SessionID,
+		SessionExpiresAt: result.SessionExpiresAt,
+		TokenId:          result.TokenID,
+		ExpiresAt:        expiresAt,
+	})
+	if err != nil {
+		log.Error("failed to save idempotent login", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+	}
+
+	return result, nil
+}
+
+// replayIdempotentLogin rebuilds a stored login result. It returns nil if
+// the result can't be replayed, because its session or token was revoked
+// meanwhile or it can't be unsealed, so the caller logs in afresh.
+func (auth *Auth) replayIdempotentLogin(
+	ctx context.Context,
+	log *slog.Logger,
+	scopedKey string,
+	stored *models.IdempotentLogin,
+) (*LoginResult, error) {
+	if err := auth.checkSession(ctx, stored.SessionId); err != nil {
+		if errors.Is(err, ErrSessionNotActive) {
+			log.Info("session of idempotent login is no longer active")
+
+			return nil, nil
+		}
+
+		log.Error("failed to check session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	if err := auth.checkTokenRevoked(ctx, stored.TokenId); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			log.Info("token of idempotent login was revoked")
+
+			return nil, nil
+		}
+
+		log.Error("failed to check token revocation", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	tokens, err := openIdempotentTokens(scopedKey, stored.SealedTokens)
+	if err != nil {
+		log.Warn("failed to unseal idempotent login", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, nil
+	}
+
+	return &LoginResult{
+		Token:            tokens.Token,
+		ExpiresAt:        stored.TokenExpiresAt,
+		SessionID:        stored.SessionId,
+		SessionExpiresAt: stored.SessionExpiresAt,
+		TokenID:          stored.TokenId,
+		CSRFToken:        tokens.CSRFToken,
+	}, nil
+}
+
+// idempotentTokensInfo is the HKDF info of the key sealing stored tokens.
+const idempotentTokensInfo = "sso idempotent login v1"
+
+// idempotentTokens are the bearer secrets of a stored login result.
+type idempotentTokens struct {
+	Token     string `json:"token"`
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
+// sealIdempotentTokens encrypts tokens with AES-GCM under a key derived
+// from the scoped idempotency key. Only its hash is stored, so the store
+// alone can't reveal the tokens.
+func sealIdempotentTokens(random io.Reader, scopedKey string, tokens idempotentTokens) ([]byte, error) {
+	aead, err := idempotencyAEAD(scopedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openIdempotentTokens(scopedKey string, sealed []byte) (idempotentTokens, error) {
+	var tokens idempotentTokens
+
+	aead, err := idempotencyAEAD(scopedKey)
+	if err != nil {
+		return tokens, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return tokens, errInvalidSealedTokens
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return tokens, err
+	}
+
+	err = json.Unmarshal(plaintext, &tokens)
+
+	return tokens, err
+}
+
+func idempotencyAEAD(scopedKey string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, []byte(scopedKey), nil, idempotentTokensInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotentLogin(t *testing.T) {
+	const key = "5f0c6a4e-8d1b-4c3e-9a7f-2b6d8e0f1a3c"
+
+	tests := []struct {
+		name string
+		// retry makes the second login, after the first one used key.
+		retry    func(t *testing.T, f *fixture, first *LoginResult) (*LoginResult, error)
+		wantSame bool
+		wantErr  error
+	}{
+		{
+			name: "retried with the key",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				f.clock.Advance(time.Minute)
+
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey(key))
+			},
+			wantSame: true,
+		},
+		{
+			name: "other key",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey("other"))
+			},
+		},
+		{
+			name: "no key",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID)
+			},
+		},
+		{
+			name: "wrong password",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				return f.auth.Login(context.Background(), "user@example.com", "wrong", testAppID, LoginWithIdempotencyKey(key))
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "other user",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				return f.auth.Login(context.Background(), "other@example.com", testPassword, testAppID, LoginWithIdempotencyKey(key))
+			},
+		},
+		{
+			name: "after the window",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				f.clock.Advance(10 * time.Minute)
+
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey(key))
+			},
+		},
+		{
+			name: "session revoked",
+			retry: func(t *testing.T, f *fixture, first *LoginResult) (*LoginResult, error) {
+				if err := f.auth.RevokeSession(context.Background(), first.SessionID); err != nil {
+					t.Fatalf("RevokeSession: %v", err)
+				}
+
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey(key))
+			},
+		},
+		{
+			name: "token revoked",
+			retry: func(t *testing.T, f *fixture, first *LoginResult) (*LoginResult, error) {
+				if err := f.auth.RevokeToken(context.Background(), first.TokenID, first.ExpiresAt); err != nil {
+					t.Fatalf("RevokeToken: %v", err)
+				}
+
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey(key))
+			},
+		},
+		{
+			name: "corrupted record",
+			retry: func(_ *testing.T, f *fixture, _ *LoginResult) (*LoginResult, error) {
+				store := f.auth.idempotencyStore.(*fakeIdempotency)
+				for _, login := range store.logins {
+					login.SealedTokens[len(login.SealedTokens)-1] ^= 1
+				}
+
+				return f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey(key))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t,
+				WithSessionStore(newFakeSessions()),
+				WithIdempotentLogins(newFakeIdempotency(), 5*time.Minute),
+				WithCSRFKey([]byte("0123456789abcdef")),
+				WithTokenRevocations(newFakeRevocations(), 0),
+			)
+			f.addUser(t, "user@example.com", testPassword)
+			f.addUser(t, "other@example.com", testPassword)
+
+			first := f.login(t, "user@example.com", LoginWithIdempotencyKey(key))
+
+			second, err := tt.retry(t, f, first)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login(retry) error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if same := *second == *first; same != tt.wantSame {
+				t.Fatalf("retry returned the first login = %v, want %v\nfirst:  %+v\nsecond: %+v", same, tt.wantSame, first, second)
+			}
+
+			claims, err := f.auth.ValidateToken(context.Background(), second.Token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken(retry): %v", err)
+			}
+
+			if claims.TokenID != second.TokenID {
+				t.Fatalf("retry TokenID = %q, want the token's %q", second.TokenID, claims.TokenID)
+			}
+		})
+	}
+}
+
+func TestIdempotentLoginKeepsTokensSealed(t *testing.T) {
+	store := newFakeIdempotency()
+	f := newFixture(t, WithSessionStore(newFakeSessions()), WithIdempotentLogins(store, 0))
+	f.addUser(t, "user@example.com", testPassword)
+
+	result := f.login(t, "user@example.com", LoginWithIdempotencyKey("key"))
+
+	if len(store.logins) != 1 {
+		t.Fatalf("%d idempotent logins stored, want 1", len(store.logins))
+	}
+
+	for _, login := range store.logins {
+		if bytes.Contains(login.SealedTokens, []byte(result.Token)) || bytes.Contains(login.KeyHash, []byte("key")) {
+			t.Fatal("stored login reveals the token or the key")
+		}
+
+		if want := testEpoch.Add(defaultIdempotencyWindow); !login.ExpiresAt.Equal(want) {
+			t.Errorf("stored login expires at %v, want %v", login.ExpiresAt, want)
+		}
+	}
+}
+
+func TestIdempotentLoginStoreError(t *testing.T) {
+	store := newFakeIdempotency()
+	store.err = errors.New("store down")
+
+	f := newFixture(t, WithIdempotentLogins(store, 0))
+	f.addUser(t, "user@example.com", testPassword)
+
+	if _, err := f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID, LoginWithIdempotencyKey("key")); !errors.Is(err, store.err) {
+		t.Fatalf("Login() error = %v, want %v", err, store.err)
+	}
+}
+
+func TestSealIdempotentTokens(t *testing.T) {
+	tokens := idempotentTokens{Token: "token", CSRFToken: "csrf"}
+
+	sealed, err := sealIdempotentTokens(bytes.NewReader(bytes.Repeat([]byte{7}, 64)), "1:1:key", tokens)
+	if err != nil {
+		t.Fatalf("sealIdempotentTokens: %v", err)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		key     string
+		sealed  []byte
+		wantErr bool
+	}{
+		{name: "same key", key: "1:1:key", sealed: sealed},
+		{name: "other scope", key: "2:1:key", sealed: sealed, wantErr: true},
+		{name: "tampered", key: "1:1:key", sealed: tampered, wantErr: true},
+		{name: "too short", key: "1:1:key", sealed: sealed[:4], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := openIdempotentTokens(tt.key, tt.sealed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openIdempotentTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && got != tokens {
+				t.Fatalf("openIdempotentTokens() = %+v, want %+v", got, tokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+const (
+	// bcryptHashLen is the length of "$2b$10$" followed by the 22 character
+	// salt and 31 character digest.
+	bcryptHashLen  = 60
+	bcryptMinCost  = 4
+	bcryptMaxCost  = 31
+	bcryptAlphabet = "./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// ValidateBcryptHash checks that hash, raw or base64-encoded, is a well-formed
+// bcrypt hash: a known version prefix, a cost in range and a salt and digest
+// of the right length and alphabet.
+func ValidateBcryptHash(hash []byte) error {
+	h := string(decodeBcrypt(hash))
+
+	if len(h) != bcryptHashLen {
+		return ErrInvalidHash
+	}
+
+	switch h[:4] {
+	case "$2a$", "$2b$", "$2y$":
+	default:
+		return ErrInvalidHash
+	}
+
+	if h[4] < '0' || h[4] > '9' || h[5] < '0' || h[5] > '9' || h[6] != '$' {
+		return ErrInvalidHash
+	}
+
+	if cost := int(h[4]-'0')*10 + int(h[5]-'0'); cost < bcryptMinCost || cost > bcryptMaxCost {
+		return ErrInvalidHash
+	}
+
+	for i := 7; i < len(h); i++ {
+		if strings.IndexByte(bcryptAlphabet, h[i]) < 0 {
+			return ErrInvalidHash
+		}
+	}
+
+	return nil
+}
+
+// ImportUser saves a user migrated from another system with an existing
+// bcrypt hash. Malformed hashes are rejected, since such a user could never
+// log in. The caller must be an admin.
+func (auth *Auth) ImportUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	const op = "auth.ImportUser"
+
+	email = normalizeEmail(email)
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := ValidateBcryptHash(passHash); err != nil {
+		log.Warn("imported hash rejected")
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := auth.saveUser(ctx, email, passHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("user already exists", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+
+		log.Error("failed to save user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.audit(ctx, models.AuditEvent{
+		Type:   AuditUserRegistered,
+		UserId: userID,
+	})
+
+	log.Info("user imported")
+
+	return userID, nil
+}
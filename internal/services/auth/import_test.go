@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+func TestValidateBcryptHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+
+	valid := string(hash)
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr error
+	}{
+		{name: "generated", hash: valid},
+		{name: "base64", hash: base64.StdEncoding.EncodeToString(hash)},
+		{name: "2b prefix", hash: "$2b$" + valid[4:]},
+		{name: "2y prefix", hash: "$2y$" + valid[4:]},
+		{name: "empty", hash: "", wantErr: ErrInvalidHash},
+		{name: "truncated", hash: valid[:59], wantErr: ErrInvalidHash},
+		{name: "unknown version", hash: "$2x$" + valid[4:], wantErr: ErrInvalidHash},
+		{name: "cost too low", hash: valid[:4] + "03" + valid[6:], wantErr: ErrInvalidHash},
+		{name: "cost too high", hash: valid[:4] + "32" + valid[6:], wantErr: ErrInvalidHash},
+		{name: "non-numeric cost", hash: valid[:4] + "1a" + valid[6:], wantErr: ErrInvalidHash},
+		{name: "bad alphabet", hash: valid[:59] + "!", wantErr: ErrInvalidHash},
+		{name: "plaintext", hash: strings.Repeat("p", bcryptHashLen), wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateBcryptHash([]byte(tt.hash)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateBcryptHash() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportUser(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		anonymous bool
+		existing  bool
+		hash      []byte
+		wantErr   error
+	}{
+		{name: "imported", hash: hash},
+		{name: "malformed hash", hash: []byte("not-a-hash"), wantErr: ErrInvalidHash},
+		{name: "existing user", existing: true, hash: hash, wantErr: ErrUserExists},
+		{name: "not admin", anonymous: true, hash: hash, wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+
+			ctx := f.addAdmin(t)
+			if tt.anonymous {
+				ctx = context.Background()
+			}
+
+			if tt.existing {
+				f.addUser(t, "migrated@example.com", testPassword)
+			}
+
+			if _, err := f.auth.ImportUser(ctx, "Migrated@Example.com", tt.hash); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ImportUser() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if _, err := f.auth.Login(context.Background(), "migrated@example.com", testPassword, testAppID); err != nil {
+				t.Fatalf("Login as imported user: %v", err)
+			}
+		})
+	}
+}
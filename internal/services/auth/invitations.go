@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrInvalidInvitation = errors.New("invalid invitation")
+	ErrInvitationExpired = errors.New("invitation expired")
+	ErrInvitationUsed    = errors.New("invitation already used")
+)
+
+// defaultInvitationTTL is used when WithInvitations gets no TTL.
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+// InvitationStore keeps single-use invitations by the hash of their token.
+type InvitationStore interface {
+	SaveInvitation(ctx context.Context, invitation models.Invitation) error
+	Invitation(ctx context.Context, hash []byte) (*models.Invitation, error)
+	// ConsumeInvitation marks the invitation used and saves its user, with
+	// the invited roles and the email already verified, in one transaction.
+	// It returns storage.ErrInvitationUsed if the invitation already was
+	// used and storage.ErrUserExists if the email is taken; either way
+	// nothing is changed.
+	ConsumeInvitation(
+		ctx context.Context,
+		hash []byte,
+		passHash []byte,
+		usedAt time.Time,
+	) (userID int64, err error)
+}
+
+// WithInvitations enables invite-only registration backed by store.
+// Invitations expire after ttl, or a week if ttl is zero.
+func WithInvitations(store InvitationStore, ttl time.Duration) Option {
+	return func(auth *Auth) {
+		if ttl <= 0 {
+			ttl = defaultInvitationTTL
+		}
+
+		auth.invitationStore = store
+		auth.invitationTTL = ttl
+	}
+}
+
+// CreateInvitation invites email to register with roles and returns the
+// invitation token. inviterUserID is recorded as the inviter. It must be
+// the authenticated caller, see clientinfo.UserID, who must be an admin, so
+// callers can't invite in another admin's name.
+func (auth *Auth) CreateInvitation(
+	ctx context.Context,
+	inviterUserID int64,
+	email string,
+	roles []string,
+) (string, error) {
+	const op = "auth.CreateInvitation"
+
+	email = normalizeEmail(email)
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+		slog.Int64("inviterID", inviterUserID),
+	)
+
+	if auth.invitationStore == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if callerID, _ := clientinfo.UserID(ctx); callerID != inviterUserID {
+		log.Warn("invitation in the name of another user", slog.Int64("callerID", callerID))
+
+		return "", fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	token, err := auth.newOpaqueToken()
+	if err != nil {
+		log.Error("failed to generate invitation token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = auth.invitationStore.SaveInvitation(ctx, models.Invitation{
+		Hash:      hashToken(token),
+		InviterId: inviterUserID,
+		Email:     email,
+		Roles:     roles,
+		ExpiresAt: auth.now().Add(auth.invitationTTL),
+	})
+	if err != nil {
+		log.Error("failed to save invitation", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invitation created")
+
+	return token, nil
+}
+
+// AcceptInvitation consumes the invitation and creates its user with the
+// invited roles and a verified email. Both happen atomically, so a failed
+// save leaves the invitation usable and concurrent accepts can't create two
+// users.
+func (auth *Auth) AcceptInvitation(ctx context.Context, token string, password string) (int64, error) {
+	const op = "auth.AcceptInvitation"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if auth.invitationStore == nil {
+		return 0, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	hash := hashToken(token)
+
+	invitation, err := auth.invitationStore.Invitation(ctx, hash)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+		}
+
+		log.Error("failed to get invitation", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("email", invitation.Email))
+
+	now := auth.now()
+
+	if !invitation.UsedAt.IsZero() {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvitationUsed)
+	}
+
+	if !now.Before(invitation.ExpiresAt) {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvitationExpired)
+	}
+
+	if err := auth.checkNewPassword(invitation.Email, password); err != nil {
+		log.Warn("password rejected", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.hashPassword(ctx, password)
+	if err != nil {
+		log.Error("failed to generate password hash", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := auth.invitationStore.ConsumeInvitation(ctx, hash, passHash, now)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationUsed) {
+			return 0, fmt.Errorf("%s: %w", op, ErrInvitationUsed)
+		}
+
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("user already exists", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+
+		log.Error("failed to consume invitation", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.emit(ctx, models.Event{Type: EventUserRegistered, UserId: userID})
+	auth.audit(ctx, models.AuditEvent{
+		Type:    AuditUserRegistered,
+		UserId:  userID,
+		Details: map[string]string{"invitedBy": fmt.Sprint(invitation.InviterId)},
+	})
+
+	log.Info("invitation accepted", slog.Int64("userID", userID))
+
+	return userID, nil
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/lib/clientinfo"
+	"sync"
+	"testing"
+	"time"
+)
+
+// invite creates an invitation for invitee@example.com from a new admin.
+func invite(t *testing.T, f *fixture) (string, error) {
+	t.Helper()
+
+	adminCtx := f.addAdmin(t)
+	adminID, _ := clientinfo.UserID(adminCtx)
+
+	return f.auth.CreateInvitation(adminCtx, adminID, "invitee@example.com", nil)
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	audit := &fakeAudit{}
+	f := newFixture(t, WithAuditStore(audit))
+	invitations := newFakeInvitations(f.users)
+	WithInvitations(invitations, 0)(f.auth)
+
+	adminCtx := f.addAdmin(t)
+	adminID, _ := clientinfo.UserID(adminCtx)
+
+	token, err := f.auth.CreateInvitation(adminCtx, adminID, " Invitee@Example.com ", []string{"editor"})
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+
+	invitation := invitations.only()
+	if invitation.Email != "invitee@example.com" {
+		t.Errorf("invitation email = %q, want the normalized email", invitation.Email)
+	}
+
+	if invitation.InviterId != adminID {
+		t.Errorf("inviter = %d, want %d", invitation.InviterId, adminID)
+	}
+
+	if want := testEpoch.Add(defaultInvitationTTL); !invitation.ExpiresAt.Equal(want) {
+		t.Errorf("invitation expires at %v, want %v", invitation.ExpiresAt, want)
+	}
+
+	userID, err := f.auth.AcceptInvitation(context.Background(), token, testPassword)
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+
+	user := f.users.get(userID)
+	if user.Name != "invitee@example.com" || !user.EmailVerified || !slices.Equal(user.Roles, []string{"editor"}) {
+		t.Fatalf("accepted user = %+v, want a verified editor", user)
+	}
+
+	if _, err := f.auth.Login(context.Background(), "invitee@example.com", testPassword, testAppID); err != nil {
+		t.Fatalf("Login as invitee: %v", err)
+	}
+
+	if _, err := f.auth.AcceptInvitation(context.Background(), token, testPassword); !errors.Is(err, ErrInvitationUsed) {
+		t.Fatalf("AcceptInvitation(again) error = %v, want %v", err, ErrInvitationUsed)
+	}
+
+	if got := audit.types(); !slices.Contains(got, AuditUserRegistered) {
+		t.Fatalf("audited %v, want a registration", got)
+	}
+}
+
+func TestAcceptInvitationRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    func(token string) string
+		elapsed  time.Duration
+		taken    bool
+		password string
+		wantErr  error
+	}{
+		{name: "unknown token", token: func(string) string { return "unknown" }, wantErr: ErrInvalidInvitation},
+		{name: "expired", elapsed: time.Hour, wantErr: ErrInvitationExpired},
+		{name: "similar password", password: "invitee-2024", wantErr: ErrPasswordTooSimilar},
+		{name: "email taken", taken: true, wantErr: ErrUserExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			invitations := newFakeInvitations(f.users)
+			WithInvitations(invitations, time.Hour)(f.auth)
+
+			token, err := invite(t, f)
+			if err != nil {
+				t.Fatalf("CreateInvitation: %v", err)
+			}
+
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			if tt.taken {
+				f.addUser(t, "invitee@example.com", testPassword)
+			}
+
+			password := testPassword
+			if tt.password != "" {
+				password = tt.password
+			}
+
+			f.clock.Advance(tt.elapsed)
+
+			if _, err := f.auth.AcceptInvitation(context.Background(), token, password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcceptInvitation() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if invitation := invitations.only(); !invitation.UsedAt.IsZero() {
+				t.Fatal("rejected invitation was consumed")
+			}
+		})
+	}
+}
+
+func TestAcceptInvitationConcurrently(t *testing.T) {
+	f := newFixture(t)
+	WithInvitations(newFakeInvitations(f.users), time.Hour)(f.auth)
+
+	token, err := invite(t, f)
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+
+	const attempts = 8
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, attempts)
+	)
+
+	for i := range attempts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, errs[i] = f.auth.AcceptInvitation(context.Background(), token, testPassword)
+		}()
+	}
+
+	wg.Wait()
+
+	var accepted int
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, ErrInvitationUsed):
+			t.Errorf("AcceptInvitation() error = %v, want nil or %v", err, ErrInvitationUsed)
+		}
+	}
+
+	if accepted != 1 {
+		t.Fatalf("%d accepts succeeded, want 1", accepted)
+	}
+}
+
+func TestCreateInvitationRequiresAdmin(t *testing.T) {
+	f := newFixture(t, WithInvitations(newFakeInvitations(nil), 0))
+	adminCtx := f.addAdmin(t)
+	adminID, _ := clientinfo.UserID(adminCtx)
+	user := f.addUser(t, "user@example.com", testPassword)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		inviterID int64
+		wantErr   error
+	}{
+		{name: "user", ctx: clientinfo.WithUserID(context.Background(), int64(user.Id)), inviterID: int64(user.Id), wantErr: ErrPermissionDenied},
+		{name: "user in an admin's name", ctx: clientinfo.WithUserID(context.Background(), int64(user.Id)), inviterID: adminID, wantErr: ErrPermissionDenied},
+		{name: "admin in another user's name", ctx: adminCtx, inviterID: int64(user.Id), wantErr: ErrPermissionDenied},
+		{name: "anonymous", ctx: context.Background(), inviterID: adminID, wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.auth.CreateInvitation(tt.ctx, tt.inviterID, "invitee@example.com", nil); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateInvitation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvitationsNotConfigured(t *testing.T) {
+	f := newFixture(t)
+
+	adminCtx := f.addAdmin(t)
+	adminID, _ := clientinfo.UserID(adminCtx)
+
+	if _, err := f.auth.CreateInvitation(adminCtx, adminID, "invitee@example.com", nil); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("CreateInvitation() error = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if _, err := f.auth.AcceptInvitation(context.Background(), "token", testPassword); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("AcceptInvitation() error = %v, want %v", err, ErrNotConfigured)
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sso/internal/lib/clientinfo"
+	"strings"
+)
+
+var ErrIPBlocked = errors.New("ip address is blocked")
+
+// IPBlocklist decides whether requests from an IP are refused.
+type IPBlocklist interface {
+	IsBlocked(ip string) (bool, error)
+}
+
+// WithIPBlocklist refuses Login and RegisterNewUser from blocked client IPs.
+func WithIPBlocklist(blocklist IPBlocklist) Option {
+	return func(auth *Auth) {
+		auth.ipBlocklist = blocklist
+	}
+}
+
+// CIDRBlocklist blocks IPs within a fixed set of CIDR ranges.
+type CIDRBlocklist struct {
+	prefixes []netip.Prefix
+}
+
+// NewCIDRBlocklist parses ranges such as "203.0.113.0/24" or "2001:db8::/32".
+// Plain addresses block that address only.
+func NewCIDRBlocklist(cidrs []string) (*CIDRBlocklist, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blocklist entry %q: %w", cidr, err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocklist entry %q: %w", cidr, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return &CIDRBlocklist{prefixes: prefixes}, nil
+}
+
+func (b *CIDRBlocklist) IsBlocked(ip string) (bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, fmt.Errorf("invalid ip %q: %w", ip, err)
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range b.prefixes {
+		if prefix.Contains(addr) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkIPBlocked returns ErrIPBlocked if the client IP in ctx is blocked.
+func (auth *Auth) checkIPBlocked(ctx context.Context, log *slog.Logger) error {
+	if auth.ipBlocklist == nil {
+		return nil
+	}
+
+	ip, ok := clientinfo.IP(ctx)
+	if !ok {
+		return nil
+	}
+
+	blocked, err := auth.ipBlocklist.IsBlocked(ip)
+	if err != nil {
+		return fmt.Errorf("failed to check ip blocklist: %w", err)
+	}
+
+	if blocked {
+		log.Warn("request from blocked ip", slog.String("ip", ip))
+
+		return ErrIPBlocked
+	}
+
+	return nil
+}
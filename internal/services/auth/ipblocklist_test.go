@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/clientinfo"
+	"testing"
+)
+
+func TestCIDRBlocklist(t *testing.T) {
+	blocklist, err := NewCIDRBlocklist([]string{"203.0.113.0/24", " 198.51.100.7 ", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("NewCIDRBlocklist: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		want    bool
+		wantErr bool
+	}{
+		{ip: "203.0.113.1", want: true},
+		{ip: "203.0.114.1", want: false},
+		{ip: "198.51.100.7", want: true},
+		{ip: "198.51.100.8", want: false},
+		{ip: "::ffff:203.0.113.9", want: true},
+		{ip: "2001:db8::1", want: true},
+		{ip: "2001:db9::1", want: false},
+		{ip: "not-an-ip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := blocklist.IsBlocked(tt.ip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsBlocked() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("IsBlocked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCIDRBlocklistInvalid(t *testing.T) {
+	for _, cidr := range []string{"203.0.113.0/33", "example.com", ""} {
+		if _, err := NewCIDRBlocklist([]string{cidr}); err == nil {
+			t.Errorf("NewCIDRBlocklist(%q) error = nil, want an error", cidr)
+		}
+	}
+}
+
+func TestBlockedIP(t *testing.T) {
+	blocklist, err := NewCIDRBlocklist([]string{"203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("NewCIDRBlocklist: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ip      string
+		wantErr error
+	}{
+		{name: "blocked", ip: "203.0.113.5", wantErr: ErrIPBlocked},
+		{name: "allowed", ip: "192.0.2.1"},
+		{name: "no ip", ip: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithIPBlocklist(blocklist))
+			f.addUser(t, "user@example.com", testPassword)
+
+			ctx := context.Background()
+			if tt.ip != "" {
+				ctx = clientinfo.WithIP(ctx, tt.ip)
+			}
+
+			if _, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := f.auth.RegisterNewUser(ctx, "new@example.com", testPassword); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RegisterNewUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"log/slog"
+	"sso/internal/domain/models"
+	jwt "sso/internal/lib"
+)
+
+// WithIssuer sets the "iss" claim of issued tokens, naming this instance.
+func WithIssuer(issuer string) Option {
+	return func(auth *Auth) {
+		auth.issuer = issuer
+	}
+}
+
+// WithTrustedIssuers makes ValidateToken also accept tokens from other SSO
+// instances, keyed by their issuer name and mapped to the secret their
+// tokens are verified with. Tokens from any other issuer are rejected with
+// ErrUntrustedIssuer. Tokens of this instance, see WithIssuer, are still
+// verified with the app secret.
+func WithTrustedIssuers(secrets map[string]string) Option {
+	return func(auth *Auth) {
+		auth.trustedIssuers = secrets
+	}
+}
+
+// verificationSecret picks the secret to verify tokenString with by its
+// issuer. foreign reports a token of a trusted issuer other than this
+// instance, whose sessions are not known here.
+func (auth *Auth) verificationSecret(
+	log *slog.Logger,
+	tokenString string,
+	app *models.App,
+) (secret string, foreign bool, err error) {
+	if auth.trustedIssuers != nil {
+		issuer, err := jwt.Issuer(tokenString)
+		if err != nil {
+			log.Warn("failed to parse token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return "", false, ErrInvalidToken
+		}
+
+		if issuer != auth.issuer {
+			secret, ok := auth.trustedIssuers[issuer]
+			if !ok {
+				log.Warn("token from untrusted issuer", slog.String("issuer", issuer))
+
+				return "", false, ErrUntrustedIssuer
+			}
+
+			return secret, true, nil
+		}
+	}
+
+	secret, err = auth.appSecret(app)
+	if err != nil {
+		log.Error("failed to derive app secret", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return "", false, err
+	}
+
+	return secret, false, nil
+}
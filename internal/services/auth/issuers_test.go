@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"testing"
+)
+
+func TestTrustedIssuers(t *testing.T) {
+	const remoteSecret = "remote-secret"
+
+	local := newFixture(t,
+		WithIssuer("local"),
+		WithTrustedIssuers(map[string]string{"remote": remoteSecret, "misconfigured": "wrong-secret"}),
+		WithSessionStore(newFakeSessions()),
+	)
+	local.addUser(t, "user@example.com", testPassword)
+
+	// issue logs in through another instance and returns its token.
+	issue := func(issuer string) string {
+		t.Helper()
+
+		var opts []Option
+		if issuer != "" {
+			opts = append(opts, WithIssuer(issuer))
+		}
+
+		remote := newFixture(t, opts...)
+		remote.apps.update(testAppID, func(app *models.App) { app.Secret = remoteSecret })
+		remote.addUser(t, "user@example.com", testPassword)
+
+		return remote.login(t, "user@example.com").Token
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantIssuer string
+		wantErr    error
+	}{
+		{name: "own token", token: local.login(t, "user@example.com").Token, wantIssuer: "local"},
+		{name: "trusted issuer", token: issue("remote"), wantIssuer: "remote"},
+		{name: "untrusted issuer", token: issue("evil"), wantErr: ErrUntrustedIssuer},
+		{name: "no issuer", token: issue(""), wantErr: ErrUntrustedIssuer},
+		{name: "wrong secret for issuer", token: issue("misconfigured"), wantErr: ErrInvalidToken},
+		{name: "malformed", token: "not-a-token", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := local.auth.ValidateToken(context.Background(), tt.token, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && claims.Issuer != tt.wantIssuer {
+				t.Fatalf("ValidateToken() issuer = %q, want %q", claims.Issuer, tt.wantIssuer)
+			}
+		})
+	}
+}
+
+func TestIssuerWithoutTrustedIssuers(t *testing.T) {
+	remote := newFixture(t, WithIssuer("remote"))
+	remote.addUser(t, "user@example.com", testPassword)
+
+	// Without a trusted issuer list, tokens are verified with the app
+	// secret whatever their issuer.
+	local := newFixture(t, WithIssuer("local"))
+
+	if _, err := local.auth.ValidateToken(context.Background(), remote.login(t, "user@example.com").Token, testAppID); err != nil {
+		t.Fatalf("ValidateToken() error = %v, want nil", err)
+	}
+}
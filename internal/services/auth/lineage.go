@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// Rotation record reasons.
+const (
+	RotationIssued        = "issued"
+	RotationRotated       = "rotated"
+	RotationRevoked       = "revoked"
+	RotationReuseDetected = "reuse_detected"
+)
+
+// RotationStore keeps the history of tokens issued within a session family.
+// RefreshToken relies on it to tell the current token of a session from
+// retired ones.
+type RotationStore interface {
+	AppendRotationRecord(ctx context.Context, record models.RotationRecord) error
+	RotationRecords(ctx context.Context, sessionID string) ([]models.RotationRecord, error)
+}
+
+// WithRotationStore records session lineage in store.
+func WithRotationStore(store RotationStore) Option {
+	return func(auth *Auth) {
+		auth.rotationStore = store
+	}
+}
+
+// SessionLineage returns the rotation history of a session family, oldest
+// event first: records are ordered by rotation time, or issue time for
+// records that were not rotated. The caller must be an admin.
+func (auth *Auth) SessionLineage(ctx context.Context, sessionID string) ([]models.RotationRecord, error) {
+	const op = "auth.SessionLineage"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("sessionID", sessionID),
+	)
+
+	if auth.rotationStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	records, err := auth.rotationStore.RotationRecords(ctx, sessionID)
+	if err != nil {
+		log.Error("failed to get rotation records", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sortLineage(records), nil
+}
+
+// sortLineage orders records by rotation time, or issue time for records
+// that were not rotated. Ties keep the store's order.
+func sortLineage(records []models.RotationRecord) []models.RotationRecord {
+	slices.SortStableFunc(records, func(a, b models.RotationRecord) int {
+		return eventTime(a).Compare(eventTime(b))
+	})
+
+	return records
+}
+
+// recordRotation appends a lineage record when a rotation store is
+// configured. Failures are only logged.
+func (auth *Auth) recordRotation(
+	ctx context.Context,
+	sessionID string,
+	tokenID string,
+	issuedAt time.Time,
+	reason string,
+) {
+	if auth.rotationStore == nil {
+		return
+	}
+
+	record := models.RotationRecord{
+		SessionId: sessionID,
+		TokenId:   tokenID,
+		IssuedAt:  issuedAt,
+		Reason:    reason,
+	}
+
+	if reason != RotationIssued {
+		record.RotatedAt = auth.now()
+	}
+
+	if err := auth.rotationStore.AppendRotationRecord(ctx, record); err != nil {
+		auth.log.Error("failed to record session rotation",
+			slog.String("sessionID", sessionID),
+			slog.String("reason", reason),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+	}
+}
+
+func eventTime(record models.RotationRecord) time.Time {
+	if !record.RotatedAt.IsZero() {
+		return record.RotatedAt
+	}
+
+	return record.IssuedAt
+}
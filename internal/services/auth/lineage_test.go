@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	jwt "sso/internal/lib"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestRefreshToken(t *testing.T) {
+	sessions := newFakeSessions()
+	rotations := &fakeRotations{}
+	f := newFixture(t, WithSessionStore(sessions), WithRotationStore(rotations), WithSessionTimeouts(0, 24*time.Hour))
+	f.addUser(t, "user@example.com", testPassword)
+
+	first := f.login(t, "user@example.com")
+
+	// Expired tokens can still be refreshed while their session is active.
+	f.clock.Advance(testTokenTTL + time.Minute)
+
+	second, err := f.auth.RefreshToken(context.Background(), first.Token, testAppID)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	if second.SessionID != first.SessionID {
+		t.Fatalf("refreshed session = %q, want %q", second.SessionID, first.SessionID)
+	}
+
+	if _, err := f.auth.ValidateToken(context.Background(), second.Token, testAppID); err != nil {
+		t.Fatalf("ValidateToken(refreshed): %v", err)
+	}
+
+	f.clock.Advance(time.Minute)
+
+	if _, err := f.auth.RefreshToken(context.Background(), first.Token, testAppID); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("RefreshToken(retired) error = %v, want %v", err, ErrTokenReused)
+	}
+
+	if session := sessions.get(first.SessionID); session.RevokedAt.IsZero() {
+		t.Fatal("session not revoked after token reuse")
+	}
+
+	if _, err := f.auth.RefreshToken(context.Background(), second.Token, testAppID); !errors.Is(err, ErrSessionNotActive) {
+		t.Fatalf("RefreshToken(after reuse) error = %v, want %v", err, ErrSessionNotActive)
+	}
+
+	lineage, err := f.auth.SessionLineage(f.addAdmin(t), first.SessionID)
+	if err != nil {
+		t.Fatalf("SessionLineage: %v", err)
+	}
+
+	var reasons []string
+	for _, record := range lineage {
+		reasons = append(reasons, record.Reason)
+	}
+
+	want := []string{RotationIssued, RotationRotated, RotationReuseDetected, RotationRevoked}
+	if !slices.Equal(reasons, want) {
+		t.Fatalf("lineage reasons = %v, want %v", reasons, want)
+	}
+}
+
+func TestRefreshTokenRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to refresh, after first logged in.
+		token   func(t *testing.T, f *fixture, user *models.User, first *LoginResult) string
+		wantErr error
+	}{
+		{
+			name: "deleted user",
+			token: func(t *testing.T, f *fixture, user *models.User, first *LoginResult) string {
+				err := f.users.update(int64(user.Id), user.Version, func(user *models.User) { user.DeletedAt = testEpoch })
+				if err != nil {
+					t.Fatalf("update: %v", err)
+				}
+
+				return first.Token
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "below min token version",
+			token: func(t *testing.T, _ *fixture, user *models.User, first *LoginResult) string {
+				token, err := jwt.NewToken(user, &models.App{Id: testAppID, Secret: testAppSecret}, testEpoch, testTokenTTL,
+					jwt.WithSessionID(first.SessionID),
+					jwt.WithTokenID(first.TokenID),
+				)
+				if err != nil {
+					t.Fatalf("NewToken: %v", err)
+				}
+
+				return token
+			},
+			wantErr: ErrTokenVersionUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t,
+				WithSessionStore(newFakeSessions()),
+				WithRotationStore(&fakeRotations{}),
+				WithMinTokenVersion(TokenFormatVersion),
+			)
+			user := f.addUser(t, "user@example.com", testPassword)
+			first := f.login(t, "user@example.com")
+
+			token := tt.token(t, f, user, first)
+
+			if _, err := f.auth.RefreshToken(context.Background(), token, testAppID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefreshToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenNotConfigured(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "no stores"},
+		{name: "no rotation store", opts: []Option{WithSessionStore(newFakeSessions())}},
+		{name: "no session store", opts: []Option{WithRotationStore(&fakeRotations{})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.opts...)
+
+			if _, err := f.auth.RefreshToken(context.Background(), "token", testAppID); !errors.Is(err, ErrNotConfigured) {
+				t.Fatalf("RefreshToken() error = %v, want %v", err, ErrNotConfigured)
+			}
+		})
+	}
+}
+
+func TestSessionLineage(t *testing.T) {
+	epoch := testEpoch
+	rotations := &fakeRotations{records: []models.RotationRecord{
+		{SessionId: "s1", TokenId: "t2", IssuedAt: epoch.Add(time.Hour), RotatedAt: epoch.Add(time.Hour), Reason: RotationRotated},
+		{SessionId: "s2", TokenId: "x", IssuedAt: epoch, Reason: RotationIssued},
+		{SessionId: "s1", TokenId: "t1", IssuedAt: epoch, Reason: RotationIssued},
+		{SessionId: "s1", RotatedAt: epoch.Add(2 * time.Hour), Reason: RotationRevoked},
+	}}
+
+	f := newFixture(t, WithRotationStore(rotations))
+	user := f.addUser(t, "user@example.com", testPassword)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    []string
+		wantErr error
+	}{
+		{name: "admin", ctx: f.addAdmin(t), want: []string{"t1", "t2", ""}},
+		{name: "user", ctx: clientinfo.WithUserID(context.Background(), int64(user.Id)), wantErr: ErrPermissionDenied},
+		{name: "anonymous", ctx: context.Background(), wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lineage, err := f.auth.SessionLineage(tt.ctx, "s1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SessionLineage() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var tokenIDs []string
+			for _, record := range lineage {
+				tokenIDs = append(tokenIDs, record.TokenId)
+			}
+
+			if !slices.Equal(tokenIDs, tt.want) {
+				t.Fatalf("SessionLineage() token IDs = %q, want %q", tokenIDs, tt.want)
+			}
+		})
+	}
+}
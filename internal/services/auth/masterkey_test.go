@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"testing"
+)
+
+var (
+	testMasterKey  = bytes.Repeat([]byte("m"), minMasterKeyLen)
+	otherMasterKey = bytes.Repeat([]byte("o"), minMasterKeyLen)
+)
+
+func TestDeriveAppSecret(t *testing.T) {
+	secret, err := deriveAppSecret(testMasterKey, testAppID)
+	if err != nil {
+		t.Fatalf("deriveAppSecret: %v", err)
+	}
+
+	if len(secret) != appSecretLen {
+		t.Fatalf("secret has %d bytes, want %d", len(secret), appSecretLen)
+	}
+
+	tests := []struct {
+		name      string
+		masterKey []byte
+		appID     int32
+		wantSame  bool
+	}{
+		{name: "same inputs", masterKey: testMasterKey, appID: testAppID, wantSame: true},
+		{name: "other app", masterKey: testMasterKey, appID: testAppID + 1},
+		{name: "other master key", masterKey: otherMasterKey, appID: testAppID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deriveAppSecret(tt.masterKey, tt.appID)
+			if err != nil {
+				t.Fatalf("deriveAppSecret: %v", err)
+			}
+
+			if (got == secret) != tt.wantSame {
+				t.Fatalf("deriveAppSecret() same = %v, want %v", got == secret, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestMasterKeySignsTokens(t *testing.T) {
+	f := newFixture(t, WithMasterKey(testMasterKey))
+	f.addUser(t, "user@example.com", testPassword)
+
+	token := f.login(t, "user@example.com").Token
+
+	if _, err := f.auth.ValidateToken(context.Background(), token, testAppID); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	derived, err := deriveAppSecret(testMasterKey, testAppID)
+	if err != nil {
+		t.Fatalf("deriveAppSecret: %v", err)
+	}
+
+	adminCtx := f.addAdmin(t)
+
+	for candidate, want := range map[string]bool{derived: true, testAppSecret: false} {
+		got, err := f.auth.VerifyAppSecret(adminCtx, testAppID, candidate)
+		if err != nil {
+			t.Fatalf("VerifyAppSecret: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("VerifyAppSecret(derived %v) = %v, want %v", candidate == derived, got, want)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "stored secret"},
+		{name: "other master key", opts: []Option{WithMasterKey(otherMasterKey)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := newFixture(t, tt.opts...)
+
+			if _, err := other.auth.ValidateToken(context.Background(), token, testAppID); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestRefreshAdvisedForPreviousMasterKey(t *testing.T) {
+	issuer := newFixture(t, WithMasterKey(otherMasterKey))
+	issuer.addUser(t, "user@example.com", testPassword)
+
+	old := issuer.login(t, "user@example.com").Token
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantAdvised bool
+		wantErr     error
+	}{
+		{name: "previous master key", opts: []Option{WithMasterKey(testMasterKey), WithPreviousMasterKey(otherMasterKey)}, wantAdvised: true},
+		{name: "same master key", opts: []Option{WithMasterKey(otherMasterKey)}},
+		{name: "previous key dropped", opts: []Option{WithMasterKey(testMasterKey)}, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.opts...)
+			// The stored previous secret plays no part once a master key is set.
+			f.apps.update(testAppID, func(app *models.App) { app.PreviousSecret = "stored-previous" })
+
+			claims, err := f.auth.ValidateToken(context.Background(), old, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && claims.RefreshAdvised != tt.wantAdvised {
+				t.Fatalf("RefreshAdvised = %v, want %v", claims.RefreshAdvised, tt.wantAdvised)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package auth
+
+// Metrics receives service metrics. Implementations adapt it to the
+// metrics backend in use.
+type Metrics interface {
+	// SetActiveSessions sets the active session gauge of an app.
+	SetActiveSessions(appID int32, count int64)
+	// IncTokenVersion counts a validated token of an app by its format
+	// version, see TokenFormatVersion.
+	IncTokenVersion(appID int32, version int)
+}
+
+// WithMetrics reports service metrics to metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(auth *Auth) {
+		auth.metrics = metrics
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) SetActiveSessions(int32, int64) {}
+
+func (nopMetrics) IncTokenVersion(int32, int) {}
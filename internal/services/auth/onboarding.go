@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/storage"
+)
+
+// RegisterAndLogin registers a user and logs them into appID in one call.
+// The new user is read back from the primary, so replica lag can't hide it.
+func (auth *Auth) RegisterAndLogin(
+	ctx context.Context,
+	email string,
+	password string,
+	appID int32,
+	opts ...LoginOption,
+) (*LoginResult, error) {
+	const op = "auth.RegisterAndLogin"
+
+	email = normalizeEmail(email)
+
+	var options loginOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	// Check the app first so a bad appID doesn't leave a registered user
+	// without a token.
+	app, err := auth.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := auth.RegisterNewUser(ctx, email, password, RegisterForApp(appID)); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx = WithPrimaryReads(ctx)
+
+	user, err := auth.readUser(ctx, email)
+	if err != nil {
+		log.Error("failed to read registered user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := auth.issueLogin(ctx, log, user, app, options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
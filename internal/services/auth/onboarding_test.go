@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+		appID    int32
+		wantErr  error
+		// wantUsers is the number of users stored afterwards.
+		wantUsers int
+	}{
+		{name: "new user", appID: testAppID, wantUsers: 1},
+		{name: "existing user", existing: true, appID: testAppID, wantErr: ErrUserExists, wantUsers: 1},
+		{name: "unknown app", appID: 99, wantErr: ErrInvalidAppID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The replica never sees the new user: only primary reads find it.
+			f := newFixture(t, WithReadReplica(newFakeUsers()))
+
+			if tt.existing {
+				f.addUser(t, "user@example.com", testPassword)
+			}
+
+			result, err := f.auth.RegisterAndLogin(context.Background(), " User@Example.com", testPassword, tt.appID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterAndLogin() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := len(f.users.byID); got != tt.wantUsers {
+				t.Fatalf("%d users stored, want %d", got, tt.wantUsers)
+			}
+
+			if err != nil {
+				return
+			}
+
+			claims, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			if claims.Email != "user@example.com" {
+				t.Fatalf("token email = %q, want the registered user", claims.Email)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordTooSimilar     = errors.New("password is too similar to email")
+	ErrWeakPassword           = errors.New("password does not meet the policy")
+	ErrConcurrentModification = errors.New("user was modified concurrently")
+	// ErrPasswordLoginUnavailable is returned for accounts without a
+	// password, such as social-only ones.
+	ErrPasswordLoginUnavailable = errors.New("account has no password, use social login")
+	ErrPasswordAlreadySet       = errors.New("account already has a password")
+)
+
+// PasswordPolicy lists the requirements for new passwords.
+// The zero value accepts any password.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Requirement names reported in PolicyInfo.
+const (
+	RequirementUpper  = "uppercase"
+	RequirementLower  = "lowercase"
+	RequirementDigit  = "digit"
+	RequirementSymbol = "symbol"
+)
+
+// PolicyInfo describes the password policy for clients, so signup forms can
+// show hints and validate before submitting.
+type PolicyInfo struct {
+	MinLength    int      `json:"min_length"`
+	Requirements []string `json:"requirements"`
+}
+
+// WithPasswordPolicy enforces policy on new passwords.
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+	return func(auth *Auth) {
+		auth.passwordPolicy = policy
+	}
+}
+
+// PasswordPolicyInfo returns the configured password policy.
+func (auth *Auth) PasswordPolicyInfo() PolicyInfo {
+	policy := auth.passwordPolicy
+
+	info := PolicyInfo{
+		MinLength:    policy.MinLength,
+		Requirements: []string{},
+	}
+
+	if policy.RequireUpper {
+		info.Requirements = append(info.Requirements, RequirementUpper)
+	}
+
+	if policy.RequireLower {
+		info.Requirements = append(info.Requirements, RequirementLower)
+	}
+
+	if policy.RequireDigit {
+		info.Requirements = append(info.Requirements, RequirementDigit)
+	}
+
+	if policy.RequireSymbol {
+		info.Requirements = append(info.Requirements, RequirementSymbol)
+	}
+
+	return info
+}
+
+// check returns ErrWeakPassword if password violates the policy.
+func (policy PasswordPolicy) check(password string) error {
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return ErrWeakPassword
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if policy.RequireUpper && !hasUpper ||
+		policy.RequireLower && !hasLower ||
+		policy.RequireDigit && !hasDigit ||
+		policy.RequireSymbol && !hasSymbol {
+		return ErrWeakPassword
+	}
+
+	return nil
+}
+
+// minSimilarityLen is the shortest username that is checked for being part
+// of the password; shorter ones would reject too many unrelated passwords.
+const minSimilarityLen = 3
+
+// checkPasswordSimilarity rejects passwords that equal the email or contain
+// its local part (the username), and the other way around. Comparison is
+// case-insensitive.
+func checkPasswordSimilarity(email, password string) error {
+	email = normalizeEmail(email)
+	password = strings.ToLower(password)
+
+	if password == email {
+		return ErrPasswordTooSimilar
+	}
+
+	username, _, _ := strings.Cut(email, "@")
+	if len(username) < minSimilarityLen || len(password) < minSimilarityLen {
+		return nil
+	}
+
+	if strings.Contains(password, username) || strings.Contains(username, password) {
+		return ErrPasswordTooSimilar
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one. If another change raced this one, ErrConcurrentModification is
+// returned and the caller may retry. All sessions of the user are revoked
+// on success when a session store is configured.
+func (auth *Auth) ChangePassword(
+	ctx context.Context,
+	userID int64,
+	oldPassword string,
+	newPassword string,
+) error {
+	const op = "auth.ChangePassword"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	if auth.userUpdater == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(user.PassHash) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrPasswordLoginUnavailable)
+	}
+
+	if err := auth.comparePassword(ctx, user.PassHash, oldPassword); err != nil {
+		if errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnknownPepper) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := auth.checkNewPassword(user.Name, newPassword); err != nil {
+		log.Warn("password rejected", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.hashPassword(ctx, newPassword)
+	if err != nil {
+		log.Error("failed to generate password hash", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = auth.userUpdater.UpdatePassword(ctx, userID, passHash, user.Version, auth.now())
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			log.Warn("concurrent password change")
+
+			return fmt.Errorf("%s: %w", op, ErrConcurrentModification)
+		}
+
+		log.Error("failed to update password", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed")
+
+	auth.emit(ctx, models.Event{Type: EventPasswordChanged, UserId: userID})
+
+	if auth.sessionStore != nil {
+		if err := auth.revokeUserSessions(ctx, userID); err != nil {
+			log.Error("failed to revoke sessions", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// SetPassword adds a password to an account that has none, such as one
+// created through social login. Only the user itself may do so, as read
+// from clientinfo.UserID. Accounts that already have a password must use
+// ChangePassword, otherwise ErrPasswordAlreadySet is returned.
+func (auth *Auth) SetPassword(ctx context.Context, userID int64, password string) error {
+	const op = "auth.SetPassword"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	if auth.userUpdater == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if callerID, ok := clientinfo.UserID(ctx); !ok || callerID != userID {
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(user.PassHash) > 0 {
+		return fmt.Errorf("%s: %w", op, ErrPasswordAlreadySet)
+	}
+
+	if err := auth.checkNewPassword(user.Name, password); err != nil {
+		log.Warn("password rejected", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.hashPassword(ctx, password)
+	if err != nil {
+		log.Error("failed to generate password hash", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The version check makes a concurrent SetPassword fail instead of
+	// overwriting the password set first.
+	err = auth.userUpdater.UpdatePassword(ctx, userID, passHash, user.Version, auth.now())
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			log.Warn("concurrent password change")
+
+			return fmt.Errorf("%s: %w", op, ErrConcurrentModification)
+		}
+
+		log.Error("failed to update password", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password set")
+
+	auth.emit(ctx, models.Event{Type: EventPasswordChanged, UserId: userID})
+
+	return nil
+}
+
+// checkNewPassword applies the password policy, similarity and
+// common-password checks.
+func (auth *Auth) checkNewPassword(email, password string) error {
+	if err := auth.passwordPolicy.check(password); err != nil {
+		return err
+	}
+
+	if err := checkPasswordSimilarity(email, password); err != nil {
+		return err
+	}
+
+	if auth.commonPasswords != nil && auth.commonPasswords.IsCommon(password) {
+		return ErrCommonPassword
+	}
+
+	return nil
+}
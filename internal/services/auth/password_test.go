@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestCheckPasswordSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "unrelated", email: "alice@example.com", password: "Correct-Horse-42"},
+		{name: "equals email", email: "alice@example.com", password: "alice@example.com", wantErr: ErrPasswordTooSimilar},
+		{name: "equals email ignoring case", email: "Alice@Example.com", password: "ALICE@example.COM", wantErr: ErrPasswordTooSimilar},
+		{name: "contains username", email: "alice@example.com", password: "xxAlice2024", wantErr: ErrPasswordTooSimilar},
+		{name: "part of username", email: "alicewonderland@example.com", password: "wonder", wantErr: ErrPasswordTooSimilar},
+		{name: "short username is not checked", email: "al@example.com", password: "al-is-my-name"},
+		{name: "short password is not checked", email: "alice@example.com", password: "li"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPasswordSimilarity(tt.email, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkPasswordSimilarity() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsSimilarPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "accepted", password: testPassword},
+		{name: "username in password", password: "bob-the-builder-1", wantErr: ErrPasswordTooSimilar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+
+			_, err := f.auth.RegisterNewUser(context.Background(), "builder@example.com", tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterNewUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy PasswordPolicy
+		want   PolicyInfo
+	}{
+		{
+			name:   "zero policy",
+			policy: PasswordPolicy{},
+			want:   PolicyInfo{Requirements: []string{}},
+		},
+		{
+			name:   "all requirements",
+			policy: PasswordPolicy{MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			want: PolicyInfo{
+				MinLength:    12,
+				Requirements: []string{RequirementUpper, RequirementLower, RequirementDigit, RequirementSymbol},
+			},
+		},
+		{
+			name:   "digit only",
+			policy: PasswordPolicy{MinLength: 8, RequireDigit: true},
+			want:   PolicyInfo{MinLength: 8, Requirements: []string{RequirementDigit}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithPasswordPolicy(tt.policy))
+
+			got := f.auth.PasswordPolicyInfo()
+			if got.MinLength != tt.want.MinLength || !slices.Equal(got.Requirements, tt.want.Requirements) {
+				t.Fatalf("PasswordPolicyInfo() = %+v, want %+v", got, tt.want)
+			}
+
+			if got.Requirements == nil {
+				t.Fatal("Requirements is nil, want an empty list")
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "meets policy", password: "Abcdef1!"},
+		{name: "too short", password: "Ab1!", wantErr: ErrWeakPassword},
+		{name: "length counts runes", password: "Äbcdéf1!"},
+		{name: "no upper", password: "abcdef1!", wantErr: ErrWeakPassword},
+		{name: "no lower", password: "ABCDEF1!", wantErr: ErrWeakPassword},
+		{name: "no digit", password: "Abcdefg!", wantErr: ErrWeakPassword},
+		{name: "no symbol", password: "Abcdefg1", wantErr: ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.check(tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("check(%q) error = %v, want %v", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// racingUpdater changes the user right before each password update, as a
+// concurrent change would.
+type racingUpdater struct {
+	*fakeUsers
+}
+
+func (u racingUpdater) UpdatePassword(ctx context.Context, userID int64, passHash []byte, version int64, changedAt time.Time) error {
+	_ = u.update(userID, version, func(*models.User) {})
+
+	return u.fakeUsers.UpdatePassword(ctx, userID, passHash, version, changedAt)
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldPassword string
+		newPassword string
+		racing      bool
+		wantErr     error
+	}{
+		{name: "changed", oldPassword: testPassword, newPassword: "Battery-Staple-7"},
+		{name: "wrong current password", oldPassword: "wrong", newPassword: "Battery-Staple-7", wantErr: ErrInvalidCredentials},
+		{name: "concurrent change", oldPassword: testPassword, newPassword: "Battery-Staple-7", racing: true, wantErr: ErrConcurrentModification},
+		{name: "new password similar to email", oldPassword: testPassword, newPassword: "changer123", wantErr: ErrPasswordTooSimilar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.racing {
+				f.auth.userUpdater = racingUpdater{f.users}
+			}
+
+			user := f.addUser(t, "changer@example.com", testPassword)
+
+			err := f.auth.ChangePassword(context.Background(), int64(user.Id), tt.oldPassword, tt.newPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			wantPassword := testPassword
+			if tt.wantErr == nil {
+				wantPassword = tt.newPassword
+			}
+
+			if _, err := f.auth.Login(context.Background(), "changer@example.com", wantPassword, testAppID); err != nil {
+				t.Fatalf("Login with %q: %v", wantPassword, err)
+			}
+		})
+	}
+}
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	sessions := newFakeSessions()
+	f := newFixture(t, WithSessionStore(sessions))
+	user := f.addUser(t, "changer@example.com", testPassword)
+
+	result := f.login(t, "changer@example.com")
+
+	if err := f.auth.ChangePassword(context.Background(), int64(user.Id), testPassword, "Battery-Staple-7"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if _, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID); !errors.Is(err, ErrSessionNotActive) {
+		t.Fatalf("ValidateToken() error = %v, want %v", err, ErrSessionNotActive)
+	}
+}
+
+func TestPasswordlessAccount(t *testing.T) {
+	f := newFixture(t)
+	user := f.addUser(t, "social@example.com", "")
+	userID := int64(user.Id)
+
+	if _, err := f.auth.Login(context.Background(), "social@example.com", "", testAppID); !errors.Is(err, ErrPasswordLoginUnavailable) {
+		t.Fatalf("Login(no password) error = %v, want %v", err, ErrPasswordLoginUnavailable)
+	}
+
+	if err := f.auth.ChangePassword(context.Background(), userID, "", testPassword); !errors.Is(err, ErrPasswordLoginUnavailable) {
+		t.Fatalf("ChangePassword(no password) error = %v, want %v", err, ErrPasswordLoginUnavailable)
+	}
+
+	self := clientinfo.WithUserID(context.Background(), userID)
+
+	if err := f.auth.SetPassword(self, userID, testPassword); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+
+	f.login(t, "social@example.com")
+
+	if err := f.auth.SetPassword(self, userID, "Another-Horse-43"); !errors.Is(err, ErrPasswordAlreadySet) {
+		t.Fatalf("SetPassword(again) error = %v, want %v", err, ErrPasswordAlreadySet)
+	}
+}
+
+func TestSetPasswordRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   string
+		password string
+		wantErr  error
+	}{
+		{name: "other user", caller: "other", password: testPassword, wantErr: ErrPermissionDenied},
+		{name: "admin", caller: "admin", password: testPassword, wantErr: ErrPermissionDenied},
+		{name: "anonymous", caller: "anonymous", password: testPassword, wantErr: ErrPermissionDenied},
+		{name: "similar password", caller: "self", password: "social-2024", wantErr: ErrPasswordTooSimilar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			user := f.addUser(t, "social@example.com", "")
+			other := f.addUser(t, "other@example.com", testPassword)
+
+			callers := map[string]context.Context{
+				"self":      clientinfo.WithUserID(context.Background(), int64(user.Id)),
+				"other":     clientinfo.WithUserID(context.Background(), int64(other.Id)),
+				"admin":     f.addAdmin(t),
+				"anonymous": context.Background(),
+			}
+
+			if err := f.auth.SetPassword(callers[tt.caller], int64(user.Id), tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetPassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(f.users.get(int64(user.Id)).PassHash) != 0 {
+				t.Fatal("password set despite the error")
+			}
+		})
+	}
+}
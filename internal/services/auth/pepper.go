@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/storage"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrInvalidPepper = errors.New("pepper must be at least 16 bytes")
+	ErrUnknownPepper = errors.New("password hash uses an unknown pepper version")
+)
+
+const minPepperLen = 16
+
+// PepperStore persists peppers created by RotatePepper, typically in a
+// secrets manager rather than next to the hashes. It is shared by every
+// instance of the service, so all of them learn about rotations.
+type PepperStore interface {
+	// SavePepper returns storage.ErrPepperExists if version is taken.
+	SavePepper(ctx context.Context, version int, pepper []byte) error
+	// Pepper returns storage.ErrPepperNotFound for unknown versions.
+	Pepper(ctx context.Context, version int) ([]byte, error)
+	Peppers(ctx context.Context) (map[int][]byte, error)
+}
+
+// peppers holds every known pepper by version. Version 0 means no pepper,
+// for hashes made before peppering was enabled.
+type peppers struct {
+	mu       sync.RWMutex
+	current  int
+	versions map[int][]byte
+}
+
+// WithPeppers mixes a server-side secret into passwords before hashing.
+// New hashes use the current version, older versions are kept to verify
+// existing hashes, which are re-peppered on the user's next login. The
+// versions are copied, so RotatePepper never writes to the caller's map.
+func WithPeppers(current int, versions map[int][]byte) Option {
+	return func(auth *Auth) {
+		copied := make(map[int][]byte, len(versions))
+		for version, pepper := range versions {
+			copied[version] = bytes.Clone(pepper)
+		}
+
+		auth.peppers.current = current
+		auth.peppers.versions = copied
+	}
+}
+
+// WithPepperStore enables RotatePepper. Call LoadPeppers at startup to
+// pick up versions rotated in by other instances; versions still unknown
+// when a hash needs them are fetched from store.
+func WithPepperStore(store PepperStore) Option {
+	return func(auth *Auth) {
+		auth.pepperStore = store
+	}
+}
+
+// RotatePepper saves newPepper as the next version and makes it current.
+// Users move to it as they log in. The caller must be an admin.
+func (auth *Auth) RotatePepper(ctx context.Context, newPepper []byte) error {
+	const op = "auth.RotatePepper"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if auth.pepperStore == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(newPepper) < minPepperLen {
+		return fmt.Errorf("%s: %w", op, ErrInvalidPepper)
+	}
+
+	// Holding the lock across the save keeps concurrent rotations from
+	// claiming the same version. Rotations on other instances are caught
+	// up on first, and a race with one of them fails the save.
+	auth.peppers.mu.Lock()
+	defer auth.peppers.mu.Unlock()
+
+	stored, err := auth.pepperStore.Peppers(ctx)
+	if err != nil {
+		log.Error("failed to load peppers", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for version, pepper := range stored {
+		auth.peppers.add(version, pepper)
+	}
+
+	version := auth.peppers.current + 1
+
+	if err := auth.pepperStore.SavePepper(ctx, version, newPepper); err != nil {
+		if errors.Is(err, storage.ErrPepperExists) {
+			log.Warn("pepper version taken by another instance", slog.Int("version", version))
+
+			return fmt.Errorf("%s: %w", op, ErrConcurrentModification)
+		}
+
+		log.Error("failed to save pepper", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.peppers.add(version, newPepper)
+
+	log.Info("pepper rotated", slog.Int("version", version))
+
+	return nil
+}
+
+// LoadPeppers adds every pepper in the store to the known versions and
+// makes the newest one current, so an instance started after a rotation
+// peppers new hashes like the instance that rotated.
+func (auth *Auth) LoadPeppers(ctx context.Context) error {
+	const op = "auth.LoadPeppers"
+
+	if auth.pepperStore == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	stored, err := auth.pepperStore.Peppers(ctx)
+	if err != nil {
+		auth.log.Error("failed to load peppers",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.peppers.mu.Lock()
+	defer auth.peppers.mu.Unlock()
+
+	for version, pepper := range stored {
+		auth.peppers.add(version, pepper)
+	}
+
+	return nil
+}
+
+// add records a known pepper version, making it current if it is newer
+// than the current one. The caller must hold mu.
+func (p *peppers) add(version int, pepper []byte) {
+	if p.versions == nil {
+		p.versions = make(map[int][]byte)
+	}
+
+	p.versions[version] = bytes.Clone(pepper)
+	p.current = max(p.current, version)
+}
+
+// currentPepper returns the version and value new hashes are peppered with.
+func (auth *Auth) currentPepper() (int, []byte) {
+	auth.peppers.mu.RLock()
+	defer auth.peppers.mu.RUnlock()
+
+	return auth.peppers.current, auth.peppers.versions[auth.peppers.current]
+}
+
+// pepper returns the pepper of version. Versions this instance doesn't know
+// yet, rotated in elsewhere, are fetched from the pepper store.
+func (auth *Auth) pepper(ctx context.Context, version int) ([]byte, error) {
+	if version == 0 {
+		return nil, nil
+	}
+
+	auth.peppers.mu.RLock()
+	pepper, ok := auth.peppers.versions[version]
+	auth.peppers.mu.RUnlock()
+
+	if ok {
+		return pepper, nil
+	}
+
+	if auth.pepperStore == nil {
+		return nil, ErrUnknownPepper
+	}
+
+	pepper, err := auth.pepperStore.Pepper(ctx, version)
+	if err != nil {
+		if errors.Is(err, storage.ErrPepperNotFound) {
+			return nil, ErrUnknownPepper
+		}
+
+		// Callers treat ErrUnknownPepper as an error of the service, not
+		// of the password, which a store failure is too.
+		return nil, fmt.Errorf("%w: %w", ErrUnknownPepper, err)
+	}
+
+	auth.peppers.mu.Lock()
+	auth.peppers.add(version, pepper)
+	auth.peppers.mu.Unlock()
+
+	auth.log.Info("pepper version loaded from store", slog.Int("version", version))
+
+	return pepper, nil
+}
+
+// applyPepper returns the string hashed in place of password.
+func applyPepper(pepper []byte, password string) string {
+	if pepper == nil {
+		return password
+	}
+
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Peppered hashes are stored as "$pv<version>$" followed by the hasher
+// output. Unpeppered hashes are stored as is.
+const pepperPrefix = "$pv"
+
+func addPepperVersion(version int, hash []byte) []byte {
+	if version == 0 {
+		return hash
+	}
+
+	prefixed := []byte(pepperPrefix + strconv.Itoa(version) + "$")
+
+	return append(prefixed, hash...)
+}
+
+// splitPepperVersion returns the pepper version of a stored hash and the
+// hasher output.
+func splitPepperVersion(stored []byte) (int, []byte, error) {
+	rest, ok := bytes.CutPrefix(stored, []byte(pepperPrefix))
+	if !ok {
+		return 0, stored, nil
+	}
+
+	digits, hash, ok := bytes.Cut(rest, []byte("$"))
+	if !ok {
+		return 0, nil, ErrUnknownPepper
+	}
+
+	version, err := strconv.Atoi(string(digits))
+	if err != nil || version <= 0 {
+		return 0, nil, ErrUnknownPepper
+	}
+
+	return version, hash, nil
+}
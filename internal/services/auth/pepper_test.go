@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+	"testing"
+)
+
+var (
+	firstPepper  = []byte("first-pepper-0123")
+	secondPepper = []byte("second-pepper-0123")
+)
+
+// storedPepperVersion returns the pepper version of the stored hash of email.
+func storedPepperVersion(t *testing.T, f *fixture, email string) int {
+	t.Helper()
+
+	user, err := f.users.User(context.Background(), email)
+	if err != nil {
+		t.Fatalf("User: %v", err)
+	}
+
+	version, _, err := splitPepperVersion(user.PassHash)
+	if err != nil {
+		t.Fatalf("splitPepperVersion: %v", err)
+	}
+
+	return version
+}
+
+func TestRotatePepperAcrossInstances(t *testing.T) {
+	store := newFakePeppers()
+	f := newFixture(t, WithPepperStore(store))
+	adminCtx := f.addAdmin(t)
+
+	// other is a second instance of the service, sharing the database and
+	// the pepper store with f.auth.
+	other := New(discardLogger(), f.users, f.users, f.apps, testTokenTTL,
+		WithClock(f.clock.Now),
+		WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)),
+		WithUserUpdater(f.users),
+		WithPepperStore(store),
+	)
+
+	if err := f.auth.RotatePepper(adminCtx, firstPepper); err != nil {
+		t.Fatalf("RotatePepper: %v", err)
+	}
+
+	if _, err := f.auth.RegisterNewUser(context.Background(), "user@example.com", testPassword); err != nil {
+		t.Fatalf("RegisterNewUser: %v", err)
+	}
+
+	if got := storedPepperVersion(t, f, "user@example.com"); got != 1 {
+		t.Fatalf("pepper version after registration = %d, want 1", got)
+	}
+
+	// The other instance hasn't loaded the new version, it fetches it when
+	// the hash needs it.
+	if _, err := other.Login(context.Background(), "user@example.com", testPassword, testAppID); err != nil {
+		t.Fatalf("Login through the other instance: %v", err)
+	}
+
+	if err := other.RotatePepper(adminCtx, secondPepper); err != nil {
+		t.Fatalf("RotatePepper on the other instance: %v", err)
+	}
+
+	if got, _ := other.currentPepper(); got != 2 {
+		t.Fatalf("current pepper of the other instance = %d, want 2", got)
+	}
+
+	// Logging in through the other instance moves the user to its current
+	// pepper, which the first instance then has to fetch.
+	if _, err := other.Login(context.Background(), "user@example.com", testPassword, testAppID); err != nil {
+		t.Fatalf("Login through the other instance: %v", err)
+	}
+
+	if got := storedPepperVersion(t, f, "user@example.com"); got != 2 {
+		t.Fatalf("pepper version after login = %d, want 2", got)
+	}
+
+	f.login(t, "user@example.com")
+
+	if err := f.auth.LoadPeppers(context.Background()); err != nil {
+		t.Fatalf("LoadPeppers: %v", err)
+	}
+
+	if got, pepper := f.auth.currentPepper(); got != 2 || !bytes.Equal(pepper, secondPepper) {
+		t.Fatalf("current pepper after LoadPeppers = %d, want 2", got)
+	}
+}
+
+func TestWithPeppersCopiesVersions(t *testing.T) {
+	versions := map[int][]byte{1: bytes.Clone(firstPepper)}
+
+	f := newFixture(t, WithPeppers(1, versions), WithPepperStore(newFakePeppers()))
+
+	if err := f.auth.RotatePepper(f.addAdmin(t), secondPepper); err != nil {
+		t.Fatalf("RotatePepper: %v", err)
+	}
+
+	if len(versions) != 1 {
+		t.Fatalf("caller's versions = %v, want only version 1", versions)
+	}
+
+	versions[1][0] ^= 1
+
+	if pepper, err := f.auth.pepper(context.Background(), 1); err != nil || !bytes.Equal(pepper, firstPepper) {
+		t.Fatalf("pepper(1) = %q, %v, want it unaffected by the caller's map", pepper, err)
+	}
+}
+
+func TestRotatePepperRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   *fakePeppers
+		caller  string
+		pepper  []byte
+		wantErr error
+	}{
+		{name: "not configured", caller: "admin", pepper: firstPepper, wantErr: ErrNotConfigured},
+		{name: "not admin", store: newFakePeppers(), caller: "user", pepper: firstPepper, wantErr: ErrPermissionDenied},
+		{name: "anonymous", store: newFakePeppers(), pepper: firstPepper, wantErr: ErrPermissionDenied},
+		{name: "short pepper", store: newFakePeppers(), caller: "admin", pepper: []byte("short"), wantErr: ErrInvalidPepper},
+		{
+			name:    "version taken concurrently",
+			store:   &fakePeppers{peppers: make(map[int][]byte), saveErr: storage.ErrPepperExists},
+			caller:  "admin",
+			pepper:  firstPepper,
+			wantErr: ErrConcurrentModification,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.store != nil {
+				opts = append(opts, WithPepperStore(tt.store))
+			}
+
+			f := newFixture(t, opts...)
+			adminCtx := f.addAdmin(t)
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			ctx := context.Background()
+			switch tt.caller {
+			case "admin":
+				ctx = adminCtx
+			case "user":
+				ctx = clientinfo.WithUserID(ctx, int64(user.Id))
+			}
+
+			if err := f.auth.RotatePepper(ctx, tt.pepper); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotatePepper() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got, _ := f.auth.currentPepper(); got != 0 {
+				t.Fatalf("current pepper = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestLoginWithUnknownPepper(t *testing.T) {
+	tests := []struct {
+		name  string
+		store PepperStore
+	}{
+		{name: "without store"},
+		{name: "missing from store", store: newFakePeppers()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.store != nil {
+				opts = append(opts, WithPepperStore(tt.store))
+			}
+
+			f := newFixture(t, opts...)
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			err := f.users.update(int64(user.Id), user.Version, func(user *models.User) {
+				user.PassHash = addPepperVersion(3, user.PassHash)
+			})
+			if err != nil {
+				t.Fatalf("update: %v", err)
+			}
+
+			_, err = f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID)
+			if !errors.Is(err, ErrUnknownPepper) {
+				t.Fatalf("Login() error = %v, want %v", err, ErrUnknownPepper)
+			}
+		})
+	}
+}
+
+func TestSplitPepperVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		stored      string
+		wantVersion int
+		wantHash    string
+		wantErr     error
+	}{
+		{name: "unpeppered", stored: "$2a$04$hash", wantHash: "$2a$04$hash"},
+		{name: "peppered", stored: "$pv12$$2a$04$hash", wantVersion: 12, wantHash: "$2a$04$hash"},
+		{name: "unterminated version", stored: "$pv12", wantErr: ErrUnknownPepper},
+		{name: "non-numeric version", stored: "$pvx$$2a$04$hash", wantErr: ErrUnknownPepper},
+		{name: "zero version", stored: "$pv0$$2a$04$hash", wantErr: ErrUnknownPepper},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, hash, err := splitPepperVersion([]byte(tt.stored))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("splitPepperVersion() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if version != tt.wantVersion || string(hash) != tt.wantHash {
+				t.Fatalf("splitPepperVersion() = %d, %q, want %d, %q", version, hash, tt.wantVersion, tt.wantHash)
+			}
+		})
+	}
+}
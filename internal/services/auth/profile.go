@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sso/internal/storage"
+)
+
+// UserProfilePatch lists profile fields to change. Nil fields are left
+// untouched; a non-nil empty Metadata clears it. The email is not part of
+// the profile, it changes through the verification flow.
+type UserProfilePatch struct {
+	DisplayName *string
+	Metadata    map[string]string
+}
+
+// UpdateUserProfile applies patch to the user's profile and bumps UpdatedAt.
+// The caller must be the user or an admin.
+func (auth *Auth) UpdateUserProfile(ctx context.Context, userID int64, patch UserProfilePatch) error {
+	const op = "auth.UpdateUserProfile"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	if auth.userUpdater == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireSelfOrAdmin(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	displayName := user.DisplayName
+	if patch.DisplayName != nil {
+		displayName = *patch.DisplayName
+	}
+
+	metadata := user.Metadata
+	if patch.Metadata != nil {
+		metadata = maps.Clone(patch.Metadata)
+	}
+
+	err = auth.userUpdater.UpdateProfile(ctx, userID, displayName, metadata, user.Version, auth.now())
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			return fmt.Errorf("%s: %w", op, ErrConcurrentModification)
+		}
+
+		log.Error("failed to update profile", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("profile updated")
+
+	return nil
+}
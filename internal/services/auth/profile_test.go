@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestUpdateUserProfile(t *testing.T) {
+	name := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		patch        UserProfilePatch
+		wantName     string
+		wantMetadata map[string]string
+	}{
+		{
+			name:         "display name only",
+			patch:        UserProfilePatch{DisplayName: name("New Name")},
+			wantName:     "New Name",
+			wantMetadata: map[string]string{"team": "core"},
+		},
+		{
+			name:         "metadata only",
+			patch:        UserProfilePatch{Metadata: map[string]string{"team": "infra"}},
+			wantName:     "Old Name",
+			wantMetadata: map[string]string{"team": "infra"},
+		},
+		{
+			name:         "clear metadata",
+			patch:        UserProfilePatch{Metadata: map[string]string{}},
+			wantName:     "Old Name",
+			wantMetadata: map[string]string{},
+		},
+		{
+			name:         "empty patch",
+			wantName:     "Old Name",
+			wantMetadata: map[string]string{"team": "core"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			user := f.users.add(models.User{
+				Name:        "user@example.com",
+				DisplayName: "Old Name",
+				Metadata:    map[string]string{"team": "core"},
+			})
+			ctx := clientinfo.WithUserID(context.Background(), int64(user.Id))
+
+			f.clock.Advance(time.Minute)
+
+			if err := f.auth.UpdateUserProfile(ctx, int64(user.Id), tt.patch); err != nil {
+				t.Fatalf("UpdateUserProfile: %v", err)
+			}
+
+			got := f.users.get(int64(user.Id))
+			if got.DisplayName != tt.wantName {
+				t.Errorf("DisplayName = %q, want %q", got.DisplayName, tt.wantName)
+			}
+
+			if !maps.Equal(got.Metadata, tt.wantMetadata) {
+				t.Errorf("Metadata = %v, want %v", got.Metadata, tt.wantMetadata)
+			}
+
+			if !got.UpdatedAt.Equal(f.clock.Now()) {
+				t.Errorf("UpdatedAt = %v, want %v", got.UpdatedAt, f.clock.Now())
+			}
+		})
+	}
+}
+
+func TestUpdateUserProfilePermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		wantErr error
+	}{
+		{name: "self", caller: "self"},
+		{name: "admin", caller: "admin"},
+		{name: "other user", caller: "other", wantErr: ErrPermissionDenied},
+		{name: "anonymous", caller: "anonymous", wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			adminCtx := f.addAdmin(t)
+			user := f.addUser(t, "user@example.com", testPassword)
+			other := f.addUser(t, "other@example.com", testPassword)
+
+			ctx := map[string]context.Context{
+				"self":      clientinfo.WithUserID(context.Background(), int64(user.Id)),
+				"admin":     adminCtx,
+				"other":     clientinfo.WithUserID(context.Background(), int64(other.Id)),
+				"anonymous": context.Background(),
+			}[tt.caller]
+
+			newName := "Changed"
+
+			err := f.auth.UpdateUserProfile(ctx, int64(user.Id), UserProfilePatch{DisplayName: &newName})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUserProfile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			changed := f.users.get(int64(user.Id)).DisplayName == newName
+			if changed != (tt.wantErr == nil) {
+				t.Fatalf("profile changed = %v, want %v", changed, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestUpdateUserProfileUnknownUser(t *testing.T) {
+	f := newFixture(t)
+	ctx := f.addAdmin(t)
+
+	if err := f.auth.UpdateUserProfile(ctx, 999, UserProfilePatch{}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("UpdateUserProfile() error = %v, want %v", err, ErrUserNotFound)
+	}
+}
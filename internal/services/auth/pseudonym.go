@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+)
+
+const minAnalyticsKeyLen = 16
+
+// WithAnalyticsKey sets the key of PseudonymousID. With claim set, issued
+// tokens also carry the ID in the "psid" claim.
+func WithAnalyticsKey(key []byte, claim bool) Option {
+	return func(auth *Auth) {
+		auth.analyticsKey = key
+		auth.pseudonymClaim = claim
+	}
+}
+
+// PseudonymousID returns a stable identifier of the user for analytics: an
+// HMAC of the user ID under the analytics key, which can't be traced back
+// to the user without the key. It is empty if no key is configured.
+func (auth *Auth) PseudonymousID(userID int64) string {
+	if auth.analyticsKey == nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, auth.analyticsKey)
+	mac.Write([]byte(strconv.FormatInt(userID, 10)))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
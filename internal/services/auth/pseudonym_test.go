@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	jwt "sso/internal/lib"
+	"testing"
+)
+
+var testAnalyticsKey = []byte("0123456789abcdef")
+
+func TestPseudonymousID(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    []byte
+		userID int64
+		want   string
+	}{
+		{name: "no key", userID: 42, want: ""},
+		{name: "known vector", key: testAnalyticsKey, userID: 42, want: "jSSipSbV9VZGnbi2KA46e4d6QRVcpTa-Oh8qnSvg6s0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithAnalyticsKey(tt.key, false))
+
+			if got := f.auth.PseudonymousID(tt.userID); got != tt.want {
+				t.Fatalf("PseudonymousID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	f := newFixture(t, WithAnalyticsKey(testAnalyticsKey, false))
+	other := newFixture(t, WithAnalyticsKey([]byte("fedcba9876543210"), false))
+
+	if f.auth.PseudonymousID(42) == f.auth.PseudonymousID(43) {
+		t.Error("distinct users share a pseudonymous ID")
+	}
+
+	if f.auth.PseudonymousID(42) == other.auth.PseudonymousID(42) {
+		t.Error("distinct keys yield the same pseudonymous ID")
+	}
+}
+
+func TestPseudonymousIDClaim(t *testing.T) {
+	tests := []struct {
+		name      string
+		claim     bool
+		wantClaim bool
+	}{
+		{name: "claim", claim: true, wantClaim: true},
+		{name: "no claim"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithAnalyticsKey(testAnalyticsKey, tt.claim))
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			claims, err := f.auth.ValidateToken(context.Background(), f.login(t, "user@example.com").Token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			psid, ok := claims.Raw[jwt.ClaimPseudonymousID]
+			if ok != tt.wantClaim {
+				t.Fatalf("psid claim present = %v, want %v", ok, tt.wantClaim)
+			}
+
+			if ok && psid != f.auth.PseudonymousID(int64(user.Id)) {
+				t.Fatalf("psid claim = %v, want the user's pseudonymous ID", psid)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrInvalidRetention = errors.New("retention period must be positive")
+
+type UserPurger interface {
+	// SoftDeleteUser sets the user's DeletedAt and returns
+	// storage.ErrUserNotFound for unknown or already deleted users.
+	SoftDeleteUser(ctx context.Context, userID int64, deletedAt time.Time) error
+	// PurgeDeletedUsers hard-deletes users soft-deleted before deletedBefore,
+	// together with their sessions and roles, and returns how many were
+	// removed.
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error)
+}
+
+// WithUserPurger enables DeleteUser and PurgeDeleted.
+func WithUserPurger(purger UserPurger) Option {
+	return func(auth *Auth) {
+		auth.userPurger = purger
+	}
+}
+
+// DeleteUser soft-deletes the user: it can no longer log in and its
+// sessions are revoked, but its data is kept until PurgeDeleted removes it.
+// The caller must be the user or an admin.
+func (auth *Auth) DeleteUser(ctx context.Context, userID int64) error {
+	const op = "auth.DeleteUser"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("userID", userID),
+	)
+
+	if auth.userPurger == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireSelfOrAdmin(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.userPurger.SoftDeleteUser(ctx, userID, auth.now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to delete user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user deleted")
+
+	auth.emit(ctx, models.Event{Type: EventUserDeleted, UserId: userID})
+
+	if auth.sessionStore != nil {
+		if err := auth.revokeUserSessions(ctx, userID); err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("failed to revoke sessions", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// PurgeDeleted hard-deletes users that were soft-deleted more than olderThan
+// ago. Users deleted more recently are kept, so it is safe to run
+// periodically.
+func (auth *Auth) PurgeDeleted(ctx context.Context, olderThan time.Duration) (int, error) {
+	const op = "auth.PurgeDeleted"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Duration("olderThan", olderThan),
+	)
+
+	if auth.userPurger == nil {
+		return 0, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if olderThan <= 0 {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidRetention)
+	}
+
+	purged, err := auth.userPurger.PurgeDeletedUsers(ctx, auth.now().Add(-olderThan))
+	if err != nil {
+		log.Error("failed to purge users", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("deleted users purged", slog.Int("purged", purged))
+
+	return purged, nil
+}
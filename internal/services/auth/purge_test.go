@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestDeleteUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		wantErr error
+	}{
+		{name: "self", caller: "self"},
+		{name: "admin", caller: "admin"},
+		{name: "other user", caller: "other", wantErr: ErrPermissionDenied},
+		{name: "anonymous", caller: "anonymous", wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t, WithSessionStore(sessions))
+			WithUserPurger(f.users)(f.auth)
+
+			user := f.addUser(t, "user@example.com", testPassword)
+			other := f.addUser(t, "other@example.com", testPassword)
+			session := f.login(t, "user@example.com")
+
+			callers := map[string]context.Context{
+				"self":      clientinfo.WithUserID(context.Background(), int64(user.Id)),
+				"admin":     f.addAdmin(t),
+				"other":     clientinfo.WithUserID(context.Background(), int64(other.Id)),
+				"anonymous": context.Background(),
+			}
+
+			err := f.auth.DeleteUser(callers[tt.caller], int64(user.Id))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteUser() error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, loginErr := f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID)
+			_, validateErr := f.auth.ValidateToken(context.Background(), session.Token, testAppID)
+
+			if tt.wantErr != nil {
+				if loginErr != nil || validateErr != nil {
+					t.Fatalf("user affected by a refused delete: login %v, validate %v", loginErr, validateErr)
+				}
+
+				return
+			}
+
+			if !errors.Is(loginErr, ErrInvalidCredentials) {
+				t.Errorf("Login(deleted) error = %v, want %v", loginErr, ErrInvalidCredentials)
+			}
+
+			if !errors.Is(validateErr, ErrSessionNotActive) {
+				t.Errorf("ValidateToken(deleted) error = %v, want %v", validateErr, ErrSessionNotActive)
+			}
+
+			if err := f.auth.DeleteUser(callers[tt.caller], int64(user.Id)); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("DeleteUser(again) error = %v, want %v", err, ErrUserNotFound)
+			}
+		})
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	f := newFixture(t)
+	WithUserPurger(f.users)(f.auth)
+
+	adminCtx := f.addAdmin(t)
+	old := f.addUser(t, "old@example.com", testPassword)
+	recent := f.addUser(t, "recent@example.com", testPassword)
+	kept := f.addUser(t, "kept@example.com", testPassword)
+
+	if err := f.auth.DeleteUser(adminCtx, int64(old.Id)); err != nil {
+		t.Fatalf("DeleteUser(old): %v", err)
+	}
+
+	f.clock.Advance(48 * time.Hour)
+
+	if err := f.auth.DeleteUser(adminCtx, int64(recent.Id)); err != nil {
+		t.Fatalf("DeleteUser(recent): %v", err)
+	}
+
+	purged, err := f.auth.PurgeDeleted(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+
+	if purged != 1 {
+		t.Fatalf("PurgeDeleted() = %d, want 1", purged)
+	}
+
+	if f.users.get(int64(old.Id)) != nil {
+		t.Error("user deleted before the retention period was kept")
+	}
+
+	if f.users.get(int64(recent.Id)) == nil || f.users.get(int64(kept.Id)) == nil {
+		t.Error("recently deleted or live user was purged")
+	}
+}
+
+func TestPurgeDeletedRejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		unconfigured bool
+		olderThan    time.Duration
+		wantErr      error
+	}{
+		{name: "not configured", unconfigured: true, olderThan: time.Hour, wantErr: ErrNotConfigured},
+		{name: "zero retention", wantErr: ErrInvalidRetention},
+		{name: "negative retention", olderThan: -time.Hour, wantErr: ErrInvalidRetention},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if !tt.unconfigured {
+				WithUserPurger(f.users)(f.auth)
+			}
+
+			if _, err := f.auth.PurgeDeleted(context.Background(), tt.olderThan); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PurgeDeleted() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimiter decides whether another event for key is allowed.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+// WithRegistrationLimiter limits RegisterNewUser calls per client IP.
+// Every attempt counts, whether it succeeds or not.
+func WithRegistrationLimiter(limiter RateLimiter) Option {
+	return func(auth *Auth) {
+		auth.registrationLimiter = limiter
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestRegisterRateLimitedPerIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		ips     []string
+		wantErr []error
+	}{
+		{
+			name:    "under limit",
+			ips:     []string{"10.0.0.1", "10.0.0.1"},
+			wantErr: []error{nil, nil},
+		},
+		{
+			name:    "over limit",
+			ips:     []string{"10.0.0.1", "10.0.0.1", "10.0.0.1"},
+			wantErr: []error{nil, nil, ErrRateLimited},
+		},
+		{
+			name:    "other ip",
+			ips:     []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"},
+			wantErr: []error{nil, nil, nil},
+		},
+		{
+			name:    "no ip is not limited",
+			ips:     []string{"", "", ""},
+			wantErr: []error{nil, nil, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithRegistrationLimiter(ratelimit.New(2, time.Hour)))
+
+			for i, ip := range tt.ips {
+				ctx := clientinfo.WithIP(context.Background(), ip)
+				email := fmt.Sprintf("user%d@example.com", i)
+
+				_, err := f.auth.RegisterNewUser(ctx, email, testPassword)
+				if !errors.Is(err, tt.wantErr[i]) {
+					t.Fatalf("registration %d: error = %v, want %v", i, err, tt.wantErr[i])
+				}
+			}
+		})
+	}
+}
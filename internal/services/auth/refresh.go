@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	jwt "sso/internal/lib"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+)
+
+var ErrTokenReused = errors.New("token was already refreshed")
+
+// RefreshToken exchanges the current token of a session for a new one in
+// the same session. The presented token may have expired, as long as its
+// session is still active. Every refresh retires the presented token, so
+// presenting a retired token again means it leaked: the reuse is recorded
+// in the session lineage, the session is revoked and ErrTokenReused is
+// returned. Tokens below the minimum format version and soft-deleted users
+// are refused like by ValidateToken and Login. It requires a session store
+// and a rotation store.
+func (auth *Auth) RefreshToken(ctx context.Context, tokenString string, appID int32) (*LoginResult, error) {
+	const op = "auth.RefreshToken"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("appID", int(appID)),
+	)
+
+	if auth.sessionStore == nil || auth.rotationStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	app, err := auth.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := auth.refreshableClaims(ctx, log, tokenString, app)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("sessionID", claims.SessionID))
+
+	session, err := auth.activeSession(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotActive) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	records, err := auth.rotationStore.RotationRecords(ctx, claims.SessionID)
+	if err != nil {
+		log.Error("failed to get rotation records", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Sessions without lineage, e.g. ones started before the rotation
+	// store was configured, have no current token to compare against.
+	if current := currentTokenID(records); current != "" && claims.TokenID != current {
+		log.Warn("retired token presented, revoking session", slog.String("tokenID", claims.TokenID))
+
+		auth.recordRotation(ctx, claims.SessionID, claims.TokenID, claims.IssuedAt, RotationReuseDetected)
+
+		if err := auth.RevokeSession(ctx, claims.SessionID); err != nil {
+			log.Error("failed to revoke session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, ErrTokenReused)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Like Login, refuse soft-deleted users, their sessions must not be
+	// kept alive until the purge.
+	if !user.DeletedAt.IsZero() {
+		log.Info("refresh for deleted account")
+
+		return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	tokenID, err := auth.newID()
+	if err != nil {
+		log.Error("failed to generate token id", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokenOpts, err := auth.tokenOptions(ctx, log, user, app, claims.SessionID, tokenID, claimStrings(claims.Raw[jwt.ClaimResource]))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := auth.now()
+	accessTTL := auth.accessTTL(app, user)
+
+	token, err := auth.newToken(log, user, app, now, accessTTL, tokenOpts...)
+	if err != nil {
+		log.Error("failed to create token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Unlike other lineage records, this one must be stored: it makes the
+	// new token current, so losing it would flag the next refresh as reuse.
+	err = auth.rotationStore.AppendRotationRecord(ctx, models.RotationRecord{
+		SessionId: claims.SessionID,
+		TokenId:   tokenID,
+		IssuedAt:  now,
+		RotatedAt: now,
+		Reason:    RotationRotated,
+	})
+	if err != nil {
+		log.Error("failed to record token rotation", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.emit(ctx, models.Event{
+		Type:      EventTokenIssued,
+		UserId:    int64(user.Id),
+		AppId:     app.Id,
+		SessionId: claims.SessionID,
+		TokenId:   tokenID,
+	})
+
+	result := &LoginResult{
+		Token:            token,
+		ExpiresAt:        now.Add(accessTTL),
+		SessionID:        claims.SessionID,
+		SessionExpiresAt: session.ExpiresAt,
+		TokenID:          tokenID,
+	}
+
+	if auth.csrfKey != nil {
+		result.CSRFToken = auth.csrfToken(claims.SessionID)
+	}
+
+	log.Info("token refreshed")
+
+	return result, nil
+}
+
+// refreshableClaims verifies tokenString like ValidateToken does, except
+// that expired tokens are accepted. Tokens of other issuers can't be
+// refreshed, their sessions are not known here.
+func (auth *Auth) refreshableClaims(
+	ctx context.Context,
+	log *slog.Logger,
+	tokenString string,
+	app *models.App,
+) (*TokenClaims, error) {
+	secret, err := auth.appSecret(app)
+	if err != nil {
+		log.Error("failed to derive app secret", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, err
+	}
+
+	raw, err := jwt.ParseToken(tokenString, secret)
+	if err != nil {
+		raw, _ = auth.parseWithPreviousSecret(log, tokenString, app)
+	}
+
+	if raw == nil {
+		log.Warn("failed to parse token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := parseClaims(raw)
+	if err != nil || claims.AppID != app.Id || claims.SessionID == "" || claims.TokenID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Version < auth.minTokenVersion {
+		log.Warn("token format version unsupported", slog.Int("version", claims.Version))
+
+		return nil, ErrTokenVersionUnsupported
+	}
+
+	if !app.TokensValidAfter.IsZero() && claims.IssuedAt.Before(app.TokensValidAfter) {
+		return nil, ErrTokenRevoked
+	}
+
+	if bound, isBound := jwt.Fingerprint(raw); isBound {
+		presented, _ := clientinfo.Fingerprint(ctx)
+		if subtle.ConstantTimeCompare([]byte(bound), []byte(presented)) != 1 {
+			log.Warn("client fingerprint mismatch", slog.Int64("userID", claims.UserID))
+
+			return nil, ErrFingerprintMismatch
+		}
+	}
+
+	if err := auth.checkTokenRevoked(ctx, claims.TokenID); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// currentTokenID returns the ID of the latest token issued or rotated in
+// a session's lineage.
+func currentTokenID(records []models.RotationRecord) string {
+	var current string
+
+	for _, record := range sortLineage(records) {
+		if record.Reason == RotationIssued || record.Reason == RotationRotated {
+			current = record.TokenId
+		}
+	}
+
+	return current
+}
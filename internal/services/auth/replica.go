@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+type primaryReadsKey struct{}
+
+// WithReadReplica sends login user lookups to replica instead of the
+// primary UserProvider. Replica errors fall back to the primary.
+func WithReadReplica(replica UserProvider) Option {
+	return func(auth *Auth) {
+		auth.userReplica = replica
+	}
+}
+
+// WithPrimaryReads returns a copy of ctx whose user lookups skip the read
+// replica. Use it for reads following a write in the same request, where
+// replica lag could hide the write.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+func primaryReads(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryReadsKey{}).(bool)
+
+	return v
+}
+
+// readUser looks the user up on the replica when one is configured.
+func (auth *Auth) readUser(ctx context.Context, email string) (*models.User, error) {
+	if auth.userReplica == nil || primaryReads(ctx) {
+		return auth.userProvider.User(ctx, email)
+	}
+
+	user, err := auth.userReplica.User(ctx, email)
+	if err == nil || errors.Is(err, storage.ErrUserNotFound) {
+		return user, err
+	}
+
+	auth.log.Warn("replica lookup failed, falling back to primary",
+		slog.String("op", "auth.readUser"),
+		slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+	)
+
+	return auth.userProvider.User(ctx, email)
+}
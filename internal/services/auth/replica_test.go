@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"testing"
+)
+
+func TestReadUserFromReplica(t *testing.T) {
+	replicaDown := errors.New("replica down")
+
+	tests := []struct {
+		name string
+		// inReplica stores the user on the replica as well.
+		inReplica  bool
+		replicaErr error
+		primary    bool
+		wantName   string
+		wantErr    error
+	}{
+		{name: "served by replica", inReplica: true, wantName: "replica"},
+		{name: "replica miss is final", wantErr: storage.ErrUserNotFound},
+		{name: "replica failure falls back", replicaErr: replicaDown, wantName: "primary"},
+		{name: "primary reads skip replica", inReplica: true, primary: true, wantName: "primary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replica := newFakeUsers()
+			replica.err = tt.replicaErr
+
+			f := newFixture(t, WithReadReplica(replica))
+			f.users.add(models.User{Name: "user@example.com", DisplayName: "primary"})
+
+			if tt.inReplica {
+				replica.add(models.User{Name: "user@example.com", DisplayName: "replica"})
+			}
+
+			ctx := context.Background()
+			if tt.primary {
+				ctx = WithPrimaryReads(ctx)
+			}
+
+			user, err := f.auth.readUser(ctx, "user@example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readUser() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && user.DisplayName != tt.wantName {
+				t.Fatalf("readUser() served by %q, want %q", user.DisplayName, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestLoginWithReadReplica(t *testing.T) {
+	replica := newFakeUsers()
+	f := newFixture(t, WithReadReplica(replica))
+
+	user := f.addUser(t, "user@example.com", testPassword)
+	replica.add(*user)
+
+	f.login(t, "user@example.com")
+}
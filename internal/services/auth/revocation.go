@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/bloom"
+	"time"
+)
+
+const defaultRevocationFPRate = 0.01
+
+// RevokedTokenStore is the authoritative list of revoked tokens by ID.
+type RevokedTokenStore interface {
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	// RevokedTokenIDs returns the IDs of revoked tokens that expire after now.
+	RevokedTokenIDs(ctx context.Context, now time.Time) ([]string, error)
+}
+
+// WithTokenRevocations enables revoking single tokens by their "jti".
+// ValidateToken then rejects revoked tokens. Once a filter from
+// RevocationFilter is loaded with LoadRevocationFilter, the store is only
+// consulted for tokens the filter may contain. fpRate bounds the filter's
+// false-positive rate, 1% if out of (0, 1).
+func WithTokenRevocations(store RevokedTokenStore, fpRate float64) Option {
+	return func(auth *Auth) {
+		if fpRate <= 0 || fpRate >= 1 {
+			fpRate = defaultRevocationFPRate
+		}
+
+		auth.revokedTokens = store
+		auth.revocationFPRate = fpRate
+	}
+}
+
+// RevokeToken revokes a single access token. expiresAt is the token's own
+// expiry, after which the revocation need not be kept.
+func (auth *Auth) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	const op = "auth.RevokeToken"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("tokenID", tokenID),
+	)
+
+	if auth.revokedTokens == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.revokedTokens.RevokeToken(ctx, tokenID, expiresAt); err != nil {
+		log.Error("failed to revoke token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token revoked")
+
+	return nil
+}
+
+// RevocationFilter builds a Bloom filter of the currently revoked token
+// IDs, to be published periodically to validators that load it with
+// LoadRevocationFilter.
+func (auth *Auth) RevocationFilter(ctx context.Context) ([]byte, error) {
+	const op = "auth.RevocationFilter"
+
+	if auth.revokedTokens == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	ids, err := auth.revokedTokens.RevokedTokenIDs(ctx, auth.now())
+	if err != nil {
+		auth.log.Error("failed to list revoked tokens",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	filter := bloom.New(len(ids), auth.revocationFPRate)
+	for _, id := range ids {
+		filter.Add([]byte(id))
+	}
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// LoadRevocationFilter replaces the filter ValidateToken consults before
+// asking the revocation store. It is safe to call while validating.
+func (auth *Auth) LoadRevocationFilter(data []byte) error {
+	const op = "auth.LoadRevocationFilter"
+
+	filter := new(bloom.Filter)
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.revocationFilter.Store(filter)
+
+	return nil
+}
+
+// checkTokenRevoked returns ErrTokenRevoked for revoked tokens. Tokens the
+// loaded filter rules out pass without a store lookup.
+func (auth *Auth) checkTokenRevoked(ctx context.Context, tokenID string) error {
+	if auth.revokedTokens == nil || tokenID == "" {
+		return nil
+	}
+
+	if filter := auth.revocationFilter.Load(); filter != nil && !filter.MayContain([]byte(tokenID)) {
+		return nil
+	}
+
+	revoked, err := auth.revokedTokens.IsTokenRevoked(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/lib/bloom"
+	"testing"
+	"time"
+)
+
+// testRevocationFPRate keeps false positives of the filters in these tests
+// too rare to matter.
+const testRevocationFPRate = 1e-6
+
+func TestRevokeToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		loadFilter bool
+	}{
+		{name: "store only"},
+		{name: "with filter", loadFilter: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeRevocations()
+			f := newFixture(t, WithTokenRevocations(store, testRevocationFPRate))
+			f.addUser(t, "user@example.com", testPassword)
+
+			revoked := f.login(t, "user@example.com")
+			kept := f.login(t, "user@example.com")
+
+			if err := f.auth.RevokeToken(context.Background(), revoked.TokenID, revoked.ExpiresAt); err != nil {
+				t.Fatalf("RevokeToken: %v", err)
+			}
+
+			if tt.loadFilter {
+				data, err := f.auth.RevocationFilter(context.Background())
+				if err != nil {
+					t.Fatalf("RevocationFilter: %v", err)
+				}
+
+				if err := f.auth.LoadRevocationFilter(data); err != nil {
+					t.Fatalf("LoadRevocationFilter: %v", err)
+				}
+			}
+
+			if _, err := f.auth.ValidateToken(context.Background(), revoked.Token, testAppID); !errors.Is(err, ErrTokenRevoked) {
+				t.Fatalf("ValidateToken(revoked) error = %v, want %v", err, ErrTokenRevoked)
+			}
+
+			if _, err := f.auth.ValidateToken(context.Background(), kept.Token, testAppID); err != nil {
+				t.Fatalf("ValidateToken(kept) error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestRevocationFilterSkipsStore(t *testing.T) {
+	store := newFakeRevocations()
+	f := newFixture(t, WithTokenRevocations(store, testRevocationFPRate))
+	f.addUser(t, "user@example.com", testPassword)
+
+	for i := range 100 {
+		store.revoked[fmt.Sprintf("revoked-%d", i)] = testEpoch.Add(time.Hour)
+	}
+
+	data, err := f.auth.RevocationFilter(context.Background())
+	if err != nil {
+		t.Fatalf("RevocationFilter: %v", err)
+	}
+
+	if err := f.auth.LoadRevocationFilter(data); err != nil {
+		t.Fatalf("LoadRevocationFilter: %v", err)
+	}
+
+	result := f.login(t, "user@example.com")
+	store.lookups = 0
+
+	if _, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	if store.lookups != 0 {
+		t.Fatalf("%d store lookups for a token outside the filter, want 0", store.lookups)
+	}
+}
+
+func TestRevocationFilterContents(t *testing.T) {
+	store := newFakeRevocations()
+	f := newFixture(t, WithTokenRevocations(store, testRevocationFPRate))
+
+	store.revoked["live"] = testEpoch.Add(time.Hour)
+	store.revoked["expired"] = testEpoch.Add(-time.Hour)
+
+	data, err := f.auth.RevocationFilter(context.Background())
+	if err != nil {
+		t.Fatalf("RevocationFilter: %v", err)
+	}
+
+	filter := new(bloom.Filter)
+	if err := filter.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if !filter.MayContain([]byte("live")) {
+		t.Fatalf("filter lacks a live revocation")
+	}
+
+	if filter.MayContain([]byte("expired")) {
+		t.Fatalf("filter contains an expired revocation")
+	}
+}
+
+func TestLoadRevocationFilterInvalid(t *testing.T) {
+	store := newFakeRevocations()
+	f := newFixture(t, WithTokenRevocations(store, testRevocationFPRate))
+	f.addUser(t, "user@example.com", testPassword)
+
+	result := f.login(t, "user@example.com")
+	if err := f.auth.RevokeToken(context.Background(), result.TokenID, result.ExpiresAt); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	data, err := f.auth.RevocationFilter(context.Background())
+	if err != nil {
+		t.Fatalf("RevocationFilter: %v", err)
+	}
+
+	if err := f.auth.LoadRevocationFilter(data); err != nil {
+		t.Fatalf("LoadRevocationFilter: %v", err)
+	}
+
+	loaded := f.auth.revocationFilter.Load()
+
+	if err := f.auth.LoadRevocationFilter(data[:len(data)-1]); !errors.Is(err, bloom.ErrInvalidEncoding) {
+		t.Fatalf("LoadRevocationFilter() error = %v, want %v", err, bloom.ErrInvalidEncoding)
+	}
+
+	if f.auth.revocationFilter.Load() != loaded {
+		t.Fatalf("LoadRevocationFilter() replaced the filter on error")
+	}
+}
+
+func TestRevocationsNotConfigured(t *testing.T) {
+	f := newFixture(t)
+
+	if err := f.auth.RevokeToken(context.Background(), "token", testEpoch.Add(time.Hour)); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("RevokeToken() error = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if _, err := f.auth.RevocationFilter(context.Background()); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("RevocationFilter() error = %v, want %v", err, ErrNotConfigured)
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var (
+	ErrSelfTestFailed   = errors.New("self test failed")
+	ErrSelfTestDisabled = errors.New("self test is disabled in this environment")
+)
+
+type UserDeleter interface {
+	DeleteUser(ctx context.Context, userID int64) error
+}
+
+// WithUserDeleter enables hard-deleting users, used to clean up after
+// SelfTest.
+func WithUserDeleter(deleter UserDeleter) Option {
+	return func(auth *Auth) {
+		auth.userDeleter = deleter
+	}
+}
+
+// WithSelfTestApp sets the app SelfTest logs in to. It should not bind
+// tokens to client fingerprints.
+func WithSelfTestApp(appID int32) Option {
+	return func(auth *Auth) {
+		auth.selfTestAppID = appID
+	}
+}
+
+// SelfTest runs the whole auth chain as a synthetic check: it registers a
+// throwaway user, logs in, validates the token and checks the user is not
+// an admin, then deletes the user again. Any misbehaving step fails the
+// test with ErrSelfTestFailed. It only runs in the local, test and dev
+// environments.
+func (auth *Auth) SelfTest(ctx context.Context) (err error) {
+	const op = "auth.SelfTest"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if !selfTestAllowed(auth.env) {
+		return fmt.Errorf("%s: %w", op, ErrSelfTestDisabled)
+	}
+
+	if auth.userDeleter == nil || auth.selfTestAppID == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	id, err := auth.newID()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := auth.newOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	email := "selftest+" + id + "@" + selfTestDomain
+	// The suffix covers every character class a password policy may ask for.
+	password := secret + "Aa1!"
+
+	fail := func(step string, err error) error {
+		log.Error("self test step failed",
+			slog.String("step", step),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return fmt.Errorf("%s: %w: %s: %w", op, ErrSelfTestFailed, step, err)
+	}
+
+	ctx = context.WithValue(ctx, selfTestKey{}, true)
+
+	userID, err := auth.RegisterNewUser(ctx, email, password)
+	if err != nil {
+		return fail("register", err)
+	}
+
+	defer func() {
+		if delErr := auth.userDeleter.DeleteUser(context.WithoutCancel(ctx), userID); delErr != nil {
+			err = errors.Join(err, fail("cleanup", delErr))
+		}
+	}()
+
+	result, err := auth.Login(ctx, email, password, auth.selfTestAppID)
+	if err != nil {
+		return fail("login", err)
+	}
+
+	if auth.sessionStore != nil {
+		defer func() {
+			if revokeErr := auth.RevokeSession(context.WithoutCancel(ctx), result.SessionID); revokeErr != nil {
+				err = errors.Join(err, fail("cleanup", revokeErr))
+			}
+		}()
+	}
+
+	claims, err := auth.ValidateToken(ctx, result.Token, auth.selfTestAppID)
+	if err != nil {
+		return fail("validate", err)
+	}
+
+	if claims.UserID != userID || claims.Email != email {
+		return fail("validate", fmt.Errorf("token issued for user %d, want %d", claims.UserID, userID))
+	}
+
+	isAdmin, err := auth.IsAdmin(ctx, userID)
+	if err != nil {
+		return fail("is admin", err)
+	}
+
+	if isAdmin {
+		return fail("is admin", errors.New("throwaway user reported as admin"))
+	}
+
+	log.Info("self test passed")
+
+	return nil
+}
+
+// selfTestAllowed reports whether SelfTest may run in env. Like test mode
+// it is an allowlist, so an unset or unknown env is refused, since it may
+// well be prod.
+func selfTestAllowed(env string) bool {
+	return testModeAllowed(env) || env == envDev
+}
+
+// selfTestDomain is reserved by RFC 2606 and never resolves, so throwaway
+// users can't receive mail.
+const selfTestDomain = "selftest.invalid"
+
+type selfTestKey struct{}
+
+// isSelfTest reports whether ctx belongs to a SelfTest run.
+func isSelfTest(ctx context.Context) bool {
+	v, _ := ctx.Value(selfTestKey{}).(bool)
+
+	return v
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingDeleter fails every delete.
+type failingDeleter struct{}
+
+func (failingDeleter) DeleteUser(context.Context, int64) error {
+	return errors.New("delete failed")
+}
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(f *fixture) []Option
+		// wantUsers is the number of users left afterwards.
+		wantUsers int
+		wantErr   error
+	}{
+		{
+			name: "passes",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(testAppID), WithSessionStore(newFakeSessions())}
+			},
+		},
+		{
+			name: "strict password policy",
+			opts: func(f *fixture) []Option {
+				policy := PasswordPolicy{MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(testAppID), WithPasswordPolicy(policy)}
+			},
+		},
+		{
+			name: "failing step still cleans up",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(42)}
+			},
+			wantErr: ErrSelfTestFailed,
+		},
+		{
+			name: "failing cleanup",
+			opts: func(*fixture) []Option {
+				return []Option{WithUserDeleter(failingDeleter{}), WithSelfTestApp(testAppID)}
+			},
+			wantUsers: 1,
+			wantErr:   ErrSelfTestFailed,
+		},
+		{
+			name: "no app",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users)}
+			},
+			wantErr: ErrNotConfigured,
+		},
+		{
+			name: "prod",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(testAppID), WithEnv(envProd)}
+			},
+			wantErr: ErrSelfTestDisabled,
+		},
+		{
+			name: "dev",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(testAppID), WithEnv(envDev)}
+			},
+		},
+		{
+			name: "unset env",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(testAppID), WithEnv("")}
+			},
+			wantErr: ErrSelfTestDisabled,
+		},
+		{
+			name: "unknown env",
+			opts: func(f *fixture) []Option {
+				return []Option{WithUserDeleter(f.users), WithSelfTestApp(testAppID), WithEnv("staging-eu")}
+			},
+			wantErr: ErrSelfTestDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithEnv(envTest))
+			for _, opt := range tt.opts(f) {
+				opt(f.auth)
+			}
+
+			if err := f.auth.SelfTest(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SelfTest() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := len(f.users.byID); got != tt.wantUsers {
+				t.Fatalf("%d users left after SelfTest, want %d", got, tt.wantUsers)
+			}
+		})
+	}
+}
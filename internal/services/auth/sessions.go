@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrSessionNotActive = errors.New("session is not active")
+)
+
+// SessionStore keeps login sessions.
+type SessionStore interface {
+	SaveSession(
+		ctx context.Context,
+		session models.Session,
+	) error
+	Session(
+		ctx context.Context,
+		sessionID string,
+	) (*models.Session, error)
+	RevokeSession(
+		ctx context.Context,
+		sessionID string,
+		revokedAt time.Time,
+	) error
+	TouchSession(
+		ctx context.Context,
+		sessionID string,
+		activeAt time.Time,
+	) error
+	// CountActiveSessions counts sessions per app that are not revoked,
+	// expire after now and, unless activeSince is zero, were active since
+	// activeSince.
+	CountActiveSessions(
+		ctx context.Context,
+		now time.Time,
+		activeSince time.Time,
+	) (map[int32]int64, error)
+	// RevokeUserSessions revokes every session of the user and returns
+	// storage.ErrUserNotFound for unknown users.
+	RevokeUserSessions(
+		ctx context.Context,
+		userID int64,
+	) error
+	// ActiveUserSessions returns the sessions of the user in the app that
+	// are not revoked and expire after now.
+	ActiveUserSessions(
+		ctx context.Context,
+		userID int64,
+		appID int32,
+		now time.Time,
+	) ([]*models.Session, error)
+}
+
+// WithSessionStore enables session tracking backed by store. Tokens then
+// stay valid only while their session is active, so revoking a session
+// invalidates its access tokens right away.
+func WithSessionStore(store SessionStore) Option {
+	return func(auth *Auth) {
+		auth.sessionStore = store
+	}
+}
+
+// sessionTimeouts bound how long a session lives.
+type sessionTimeouts struct {
+	idle        time.Duration
+	maxLifetime time.Duration
+}
+
+// WithSessionTimeouts makes sessions expire after idle without activity,
+// and after maxLifetime in any case. Each validation counts as activity and
+// extends the session, up to maxLifetime. Zero disables either limit; without
+// a max lifetime sessions end with the token TTL. An app's RefreshTTL takes
+// precedence over maxLifetime.
+func WithSessionTimeouts(idle, maxLifetime time.Duration) Option {
+	return func(auth *Auth) {
+		auth.sessionTimeouts = sessionTimeouts{
+			idle:        idle,
+			maxLifetime: maxLifetime,
+		}
+	}
+}
+
+// RevokeSession revokes a single session and with it all its tokens.
+func (auth *Auth) RevokeSession(ctx context.Context, sessionID string) error {
+	const op = "auth.RevokeSession"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("sessionID", sessionID),
+	)
+
+	if auth.sessionStore == nil {
+		return fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.sessionStore.RevokeSession(ctx, sessionID, auth.now()); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+
+		log.Error("failed to revoke session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked")
+
+	auth.recordRotation(ctx, sessionID, "", time.Time{}, RotationRevoked)
+	auth.emit(ctx, models.Event{Type: EventSessionRevoked, SessionId: sessionID})
+
+	return nil
+}
+
+// ActiveSessionCount returns the number of active sessions across all apps.
+func (auth *Auth) ActiveSessionCount(ctx context.Context) (int64, error) {
+	const op = "auth.ActiveSessionCount"
+
+	byApp, err := auth.ActiveSessionCountByApp(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int64
+	for _, count := range byApp {
+		total += count
+	}
+
+	return total, nil
+}
+
+// ActiveSessionCountByApp returns the number of active sessions per app and
+// updates the active session gauges.
+func (auth *Auth) ActiveSessionCountByApp(ctx context.Context) (map[int32]int64, error) {
+	const op = "auth.ActiveSessionCountByApp"
+
+	if auth.sessionStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	now := auth.now()
+
+	var activeSince time.Time
+	if idle := auth.sessionTimeouts.idle; idle > 0 {
+		activeSince = now.Add(-idle)
+	}
+
+	byApp, err := auth.sessionStore.CountActiveSessions(ctx, now, activeSince)
+	if err != nil {
+		auth.log.Error("failed to count active sessions",
+			slog.String("op", op),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.sessionGauges.set(auth.metrics, byApp)
+
+	return byApp, nil
+}
+
+// sessionGauges remembers which apps have an active session gauge set.
+type sessionGauges struct {
+	mu       sync.Mutex
+	reported map[int32]bool
+}
+
+// set updates the gauges to byApp. Apps reported before but missing from
+// byApp no longer have active sessions, so their gauge is reset to zero.
+func (g *sessionGauges) set(metrics Metrics, byApp map[int32]int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for appID := range g.reported {
+		if _, ok := byApp[appID]; !ok {
+			metrics.SetActiveSessions(appID, 0)
+		}
+	}
+
+	g.reported = make(map[int32]bool, len(byApp))
+
+	for appID, count := range byApp {
+		metrics.SetActiveSessions(appID, count)
+		g.reported[appID] = true
+	}
+}
+
+// RevokeUsers signs out every listed user. Failures for single users, such
+// as unknown IDs, are collected in the returned map and don't stop the batch.
+// The error is only set if the batch could not run or was cancelled. The
+// caller must be an admin.
+func (auth *Auth) RevokeUsers(ctx context.Context, userIDs []int64) (map[int64]error, error) {
+	const op = "auth.RevokeUsers"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("users", len(userIDs)),
+	)
+
+	if auth.sessionStore == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfigured)
+	}
+
+	if err := auth.requireAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("revoking user sessions")
+
+	failed := make(map[int64]error)
+
+	for _, userID := range userIDs {
+		if err := ctx.Err(); err != nil {
+			return failed, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := auth.revokeUserSessions(ctx, userID); err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				err = ErrUserNotFound
+			}
+
+			log.Warn("failed to revoke user sessions",
+				slog.Int64("userID", userID),
+				slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+			)
+
+			failed[userID] = err
+		}
+	}
+
+	return failed, nil
+}
+
+// revokeUserSessions revokes every session of the user and records it in
+// the event log.
+func (auth *Auth) revokeUserSessions(ctx context.Context, userID int64) error {
+	if err := auth.sessionStore.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	auth.emit(ctx, models.Event{Type: EventUserSessionsRevoked, UserId: userID})
+
+	return nil
+}
+
+// startSession records a new session for user in app, ending after
+// lifetime, when a session store is configured. If the user reached the
+// app's session limit, its eviction policy runs first.
+func (auth *Auth) startSession(
+	ctx context.Context,
+	log *slog.Logger,
+	sessionID string,
+	userID int64,
+	app *models.App,
+	lifetime time.Duration,
+) error {
+	if auth.sessionStore == nil {
+		return nil
+	}
+
+	if err := auth.enforceSessionLimit(ctx, log, userID, app); err != nil {
+		return err
+	}
+
+	now := auth.now()
+
+	return auth.sessionStore.SaveSession(ctx, models.Session{
+		Id:             sessionID,
+		UserId:         userID,
+		AppId:          app.Id,
+		CreatedAt:      now,
+		LastActivityAt: now,
+		ExpiresAt:      now.Add(lifetime),
+	})
+}
+
+// checkSession returns ErrSessionNotActive unless the session exists and is
+// neither revoked, expired nor idle for too long, and records the activity.
+// It is a no-op without a session store.
+func (auth *Auth) checkSession(ctx context.Context, sessionID string) error {
+	if auth.sessionStore == nil {
+		return nil
+	}
+
+	_, err := auth.activeSession(ctx, sessionID)
+
+	return err
+}
+
+// activeSession is checkSession returning the session. It requires a
+// session store.
+func (auth *Auth) activeSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	if sessionID == "" {
+		return nil, ErrSessionNotActive
+	}
+
+	session, err := auth.sessionStore.Session(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return nil, ErrSessionNotActive
+		}
+
+		return nil, err
+	}
+
+	now := auth.now()
+
+	if !session.RevokedAt.IsZero() || !now.Before(session.ExpiresAt) {
+		return nil, ErrSessionNotActive
+	}
+
+	if idle := auth.sessionTimeouts.idle; idle > 0 {
+		if now.Sub(session.LastActivityAt) >= idle {
+			return nil, ErrSessionNotActive
+		}
+
+		if err := auth.sessionStore.TouchSession(ctx, sessionID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// SameSession validates both tokens for appID and reports whether they
+// belong to the same session. Tokens without a session ID, such as ones
+// issued before sessions were tracked, are compared by user instead.
+func (auth *Auth) SameSession(ctx context.Context, tokenA, tokenB string, appID int32) (bool, error) {
+	const op = "auth.SameSession"
+
+	a, err := auth.ValidateToken(ctx, tokenA, appID)
+	if err != nil {
+		return false, fmt.Errorf("%s: first token: %w", op, err)
+	}
+
+	b, err := auth.ValidateToken(ctx, tokenB, appID)
+	if err != nil {
+		return false, fmt.Errorf("%s: second token: %w", op, err)
+	}
+
+	if a.SessionID != "" && b.SessionID != "" {
+		return a.SessionID == b.SessionID, nil
+	}
+
+	return a.UserID == b.UserID && a.AppID == b.AppID, nil
+}
@@ -0,0 +1,510 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestRevokeUsers(t *testing.T) {
+	tests := []struct {
+		name string
+		// revoke lists the users to revoke by email, "unknown" stands for
+		// an ID without a user.
+		revoke      []string
+		wantRevoked []string
+		wantFailed  int
+	}{
+		{name: "one user", revoke: []string{"a@example.com"}, wantRevoked: []string{"a@example.com"}},
+		{name: "several users", revoke: []string{"a@example.com", "b@example.com"}, wantRevoked: []string{"a@example.com", "b@example.com"}},
+		{name: "unknown user doesn't stop the batch", revoke: []string{"unknown", "b@example.com"}, wantRevoked: []string{"b@example.com"}, wantFailed: 1},
+		{name: "empty", revoke: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t, WithSessionStore(sessions))
+			sessions.users = f.users
+			adminCtx := f.addAdmin(t)
+
+			results := make(map[string]*LoginResult)
+			ids := map[string]int64{"unknown": 999}
+
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				ids[email] = int64(f.addUser(t, email, testPassword).Id)
+				results[email] = f.login(t, email)
+			}
+
+			var userIDs []int64
+			for _, email := range tt.revoke {
+				userIDs = append(userIDs, ids[email])
+			}
+
+			failed, err := f.auth.RevokeUsers(adminCtx, userIDs)
+			if err != nil {
+				t.Fatalf("RevokeUsers: %v", err)
+			}
+
+			if len(failed) != tt.wantFailed {
+				t.Fatalf("RevokeUsers() failed = %v, want %d failures", failed, tt.wantFailed)
+			}
+
+			for _, err := range failed {
+				if !errors.Is(err, ErrUserNotFound) {
+					t.Fatalf("failure = %v, want %v", err, ErrUserNotFound)
+				}
+			}
+
+			for email, result := range results {
+				revoked := !sessions.get(result.SessionID).RevokedAt.IsZero()
+				want := slices.Contains(tt.wantRevoked, email)
+
+				if revoked != want {
+					t.Errorf("%s revoked = %v, want %v", email, revoked, want)
+				}
+
+				_, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID)
+				if gotInactive := errors.Is(err, ErrSessionNotActive); gotInactive != want {
+					t.Errorf("%s ValidateToken() error = %v, want inactive: %v", email, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeUsersCancelled(t *testing.T) {
+	f := newFixture(t, WithSessionStore(newFakeSessions()))
+
+	ctx, cancel := context.WithCancel(f.addAdmin(t))
+	cancel()
+
+	if _, err := f.auth.RevokeUsers(ctx, []int64{1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RevokeUsers() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRevokeUsersRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller string
+	}{
+		{name: "not admin", caller: "user"},
+		{name: "anonymous", caller: "anonymous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t, WithSessionStore(sessions))
+			sessions.users = f.users
+
+			user := f.addUser(t, "user@example.com", testPassword)
+			result := f.login(t, "user@example.com")
+
+			ctx := context.Background()
+			if tt.caller == "user" {
+				ctx = clientinfo.WithUserID(ctx, int64(user.Id))
+			}
+
+			if _, err := f.auth.RevokeUsers(ctx, []int64{int64(user.Id)}); !errors.Is(err, ErrPermissionDenied) {
+				t.Fatalf("RevokeUsers() error = %v, want %v", err, ErrPermissionDenied)
+			}
+
+			if !sessions.get(result.SessionID).RevokedAt.IsZero() {
+				t.Fatalf("session revoked by a denied RevokeUsers")
+			}
+		})
+	}
+}
+
+func TestRevokeUsersNotConfigured(t *testing.T) {
+	f := newFixture(t)
+
+	if _, err := f.auth.RevokeUsers(context.Background(), []int64{1}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("RevokeUsers() error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestValidateTokenChecksSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// change alters the session of the token before validation.
+		change  func(f *fixture, sessions *fakeSessions, sessionID string)
+		wantErr error
+	}{
+		{name: "active", change: func(*fixture, *fakeSessions, string) {}},
+		{
+			name: "revoked",
+			change: func(f *fixture, _ *fakeSessions, sessionID string) {
+				if err := f.auth.RevokeSession(context.Background(), sessionID); err != nil {
+					t.Fatalf("RevokeSession: %v", err)
+				}
+			},
+			wantErr: ErrSessionNotActive,
+		},
+		{
+			name: "missing",
+			change: func(_ *fixture, sessions *fakeSessions, sessionID string) {
+				delete(sessions.sessions, sessionID)
+			},
+			wantErr: ErrSessionNotActive,
+		},
+		{
+			name: "expired",
+			change: func(_ *fixture, sessions *fakeSessions, sessionID string) {
+				sessions.sessions[sessionID].ExpiresAt = testEpoch
+			},
+			wantErr: ErrSessionNotActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t, WithSessionStore(sessions))
+			f.addUser(t, "user@example.com", testPassword)
+
+			result := f.login(t, "user@example.com")
+			tt.change(f, sessions, result.SessionID)
+
+			claims, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && claims.SessionID != result.SessionID {
+				t.Fatalf("SessionID = %q, want %q", claims.SessionID, result.SessionID)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   bool
+		session string
+		wantErr error
+	}{
+		{name: "unknown session", store: true, session: "missing", wantErr: ErrSessionNotFound},
+		{name: "without store", session: "any", wantErr: ErrNotConfigured},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.store {
+				opts = append(opts, WithSessionStore(newFakeSessions()))
+			}
+
+			f := newFixture(t, opts...)
+
+			if err := f.auth.RevokeSession(context.Background(), tt.session); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevokeSession() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRevokeSessionKeepsOtherSessions(t *testing.T) {
+	f := newFixture(t, WithSessionStore(newFakeSessions()))
+	f.addUser(t, "user@example.com", testPassword)
+
+	revoked := f.login(t, "user@example.com")
+	kept := f.login(t, "user@example.com")
+
+	if err := f.auth.RevokeSession(context.Background(), revoked.SessionID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	if _, err := f.auth.ValidateToken(context.Background(), kept.Token, testAppID); err != nil {
+		t.Fatalf("ValidateToken() of other session: %v", err)
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		wantErr error
+	}
+
+	tests := []struct {
+		name        string
+		idle        time.Duration
+		maxLifetime time.Duration
+		steps       []step
+	}{
+		{
+			name:        "activity keeps session alive",
+			idle:        10 * time.Minute,
+			maxLifetime: 30 * time.Minute,
+			steps:       []step{{advance: 8 * time.Minute}, {advance: 8 * time.Minute}, {advance: 8 * time.Minute}},
+		},
+		{
+			name:        "idle timeout",
+			idle:        10 * time.Minute,
+			maxLifetime: 30 * time.Minute,
+			steps:       []step{{advance: 5 * time.Minute}, {advance: 11 * time.Minute, wantErr: ErrSessionNotActive}},
+		},
+		{
+			name:        "idle timeout is exclusive",
+			idle:        10 * time.Minute,
+			maxLifetime: 30 * time.Minute,
+			steps:       []step{{advance: 10 * time.Minute, wantErr: ErrSessionNotActive}},
+		},
+		{
+			name:        "max lifetime despite activity",
+			idle:        10 * time.Minute,
+			maxLifetime: 30 * time.Minute,
+			steps: []step{
+				{advance: 9 * time.Minute},
+				{advance: 9 * time.Minute},
+				{advance: 9 * time.Minute},
+				{advance: 9 * time.Minute, wantErr: ErrSessionNotActive},
+			},
+		},
+		{
+			name:        "max lifetime only",
+			maxLifetime: 30 * time.Minute,
+			steps:       []step{{advance: 25 * time.Minute}, {advance: 5 * time.Minute, wantErr: ErrSessionNotActive}},
+		},
+		{
+			name:  "idle only ends with the token TTL",
+			idle:  40 * time.Minute,
+			steps: []step{{advance: 30 * time.Minute}, {advance: 29 * time.Minute}, {advance: time.Minute, wantErr: ErrTokenExpired}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t, WithSessionStore(sessions), WithSessionTimeouts(tt.idle, tt.maxLifetime))
+			f.addUser(t, "user@example.com", testPassword)
+
+			result := f.login(t, "user@example.com")
+
+			for i, s := range tt.steps {
+				f.clock.Advance(s.advance)
+
+				_, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID)
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: ValidateToken() error = %v, want %v", i, err, s.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionTimeoutsSessionExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxLifetime time.Duration
+		appTTL      time.Duration
+		want        time.Duration
+	}{
+		{name: "token TTL by default", want: testTokenTTL},
+		{name: "max lifetime", maxLifetime: 30 * time.Minute, want: 30 * time.Minute},
+		{name: "app refresh TTL wins", maxLifetime: 30 * time.Minute, appTTL: 2 * time.Hour, want: 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			f := newFixture(t, WithSessionStore(sessions), WithSessionTimeouts(0, tt.maxLifetime))
+			f.apps.update(testAppID, func(app *models.App) { app.RefreshTTL = tt.appTTL })
+			f.addUser(t, "user@example.com", testPassword)
+
+			result := f.login(t, "user@example.com")
+
+			if want := testEpoch.Add(tt.want); !result.SessionExpiresAt.Equal(want) {
+				t.Errorf("SessionExpiresAt = %v, want %v", result.SessionExpiresAt, want)
+			}
+
+			if got := sessions.get(result.SessionID).ExpiresAt; !got.Equal(result.SessionExpiresAt) {
+				t.Errorf("stored ExpiresAt = %v, want %v", got, result.SessionExpiresAt)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRecordsActivity(t *testing.T) {
+	sessions := newFakeSessions()
+	f := newFixture(t, WithSessionStore(sessions), WithSessionTimeouts(10*time.Minute, 0))
+	f.addUser(t, "user@example.com", testPassword)
+
+	result := f.login(t, "user@example.com")
+	f.clock.Advance(time.Minute)
+
+	if _, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	if got := sessions.get(result.SessionID).LastActivityAt; !got.Equal(f.clock.Now()) {
+		t.Fatalf("LastActivityAt = %v, want %v", got, f.clock.Now())
+	}
+}
+
+func TestActiveSessionCount(t *testing.T) {
+	const otherAppID int32 = 2
+
+	tests := []struct {
+		name string
+		idle time.Duration
+		// revoke revokes the first session of the test app.
+		revoke    bool
+		advance   time.Duration
+		wantTotal int64
+		wantByApp map[int32]int64
+	}{
+		{name: "all active", wantTotal: 3, wantByApp: map[int32]int64{testAppID: 2, otherAppID: 1}},
+		{name: "revoked session", revoke: true, wantTotal: 2, wantByApp: map[int32]int64{testAppID: 1, otherAppID: 1}},
+		{name: "expired sessions", advance: 2 * testTokenTTL, wantTotal: 0, wantByApp: map[int32]int64{}},
+		{name: "idle sessions", idle: 10 * time.Minute, advance: 15 * time.Minute, wantTotal: 0, wantByApp: map[int32]int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newFakeMetrics()
+			f := newFixture(t, WithSessionStore(newFakeSessions()), WithSessionTimeouts(tt.idle, 0), WithMetrics(metrics))
+			f.apps.add(models.App{Id: otherAppID, Secret: "other-secret"})
+			f.addUser(t, "user@example.com", testPassword)
+
+			first := f.login(t, "user@example.com")
+			f.login(t, "user@example.com")
+
+			if _, err := f.auth.Login(context.Background(), "user@example.com", testPassword, otherAppID); err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			if tt.revoke {
+				if err := f.auth.RevokeSession(context.Background(), first.SessionID); err != nil {
+					t.Fatalf("RevokeSession: %v", err)
+				}
+			}
+
+			f.clock.Advance(tt.advance)
+
+			total, err := f.auth.ActiveSessionCount(context.Background())
+			if err != nil {
+				t.Fatalf("ActiveSessionCount: %v", err)
+			}
+
+			if total != tt.wantTotal {
+				t.Errorf("ActiveSessionCount() = %d, want %d", total, tt.wantTotal)
+			}
+
+			byApp, err := f.auth.ActiveSessionCountByApp(context.Background())
+			if err != nil {
+				t.Fatalf("ActiveSessionCountByApp: %v", err)
+			}
+
+			if !maps.Equal(byApp, tt.wantByApp) {
+				t.Errorf("ActiveSessionCountByApp() = %v, want %v", byApp, tt.wantByApp)
+			}
+		})
+	}
+}
+
+func TestActiveSessionGaugesReset(t *testing.T) {
+	metrics := newFakeMetrics()
+	f := newFixture(t, WithSessionStore(newFakeSessions()), WithMetrics(metrics))
+	f.addUser(t, "user@example.com", testPassword)
+
+	f.login(t, "user@example.com")
+
+	if _, err := f.auth.ActiveSessionCountByApp(context.Background()); err != nil {
+		t.Fatalf("ActiveSessionCountByApp: %v", err)
+	}
+
+	if got := metrics.activeSessions[testAppID]; got != 1 {
+		t.Fatalf("gauge = %d, want 1", got)
+	}
+
+	f.clock.Advance(2 * testTokenTTL)
+
+	if _, err := f.auth.ActiveSessionCountByApp(context.Background()); err != nil {
+		t.Fatalf("ActiveSessionCountByApp: %v", err)
+	}
+
+	if got, ok := metrics.activeSessions[testAppID]; !ok || got != 0 {
+		t.Fatalf("gauge = %d (set: %v), want reset to 0", got, ok)
+	}
+}
+
+func TestSameSession(t *testing.T) {
+	f := newFixture(t, WithSessionStore(newFakeSessions()), WithRotationStore(&fakeRotations{}))
+	f.addUser(t, "alice@example.com", testPassword)
+	f.addUser(t, "bob@example.com", testPassword)
+
+	first := f.login(t, "alice@example.com")
+	second := f.login(t, "alice@example.com")
+	bobs := f.login(t, "bob@example.com")
+
+	refreshed, err := f.auth.RefreshToken(context.Background(), first.Token, testAppID)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		a, b    string
+		want    bool
+		wantErr error
+	}{
+		{name: "same token", a: first.Token, b: first.Token, want: true},
+		{name: "refreshed token", a: first.Token, b: refreshed.Token, want: true},
+		{name: "other session of the user", a: first.Token, b: second.Token},
+		{name: "other user", a: first.Token, b: bobs.Token},
+		{name: "invalid first token", a: "not-a-token", b: first.Token, wantErr: ErrInvalidToken},
+		{name: "invalid second token", a: first.Token, b: "not-a-token", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.auth.SameSession(context.Background(), tt.a, tt.b, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SameSession() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("SameSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameSessionWithoutSessionIDs(t *testing.T) {
+	// Without a session store, tokens without a session ID still validate.
+	f := newFixture(t)
+	alice := f.addUser(t, "alice@example.com", testPassword)
+	bob := f.addUser(t, "bob@example.com", testPassword)
+
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "same user", a: legacyToken(t, alice), b: legacyToken(t, alice), want: true},
+		{name: "legacy and session token", a: legacyToken(t, alice), b: f.login(t, "alice@example.com").Token, want: true},
+		{name: "other user", a: legacyToken(t, alice), b: legacyToken(t, bob)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.auth.SameSession(context.Background(), tt.a, tt.b, testAppID)
+			if err != nil {
+				t.Fatalf("SameSession: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("SameSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	envLocal = "local"
+	envTest  = "test"
+	envDev   = "dev"
+	envProd  = "prod"
+)
+
+// testModeAllowed reports whether test mode may be enabled in env. Only
+// environments explicitly named local or test qualify, so an unset env
+// doesn't let test mode through.
+func testModeAllowed(env string) bool {
+	return env == envLocal || env == envTest
+}
+
+// testEpoch is the fixed time reported by the clock in test mode.
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// WithEnv tells the service which environment it runs in (local, test, dev,
+// prod).
+func WithEnv(env string) Option {
+	return func(auth *Auth) {
+		auth.env = env
+	}
+}
+
+// WithTestMode makes minted tokens reproducible for golden-file tests: the
+// clock is fixed and session IDs, token IDs and other random values come
+// from a generator seeded with seed. New panics unless the environment is
+// local or test, see WithEnv. The seeded generator is not safe for
+// concurrent use, so test mode suits sequential tests only.
+func WithTestMode(seed int64) Option {
+	return func(auth *Auth) {
+		var chachaSeed [32]byte
+		binary.LittleEndian.PutUint64(chachaSeed[:], uint64(seed))
+
+		auth.testMode = true
+		auth.random = rand.NewChaCha8(chachaSeed)
+		auth.now = func() time.Time { return testEpoch }
+	}
+}
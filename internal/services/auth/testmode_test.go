@@ -0,0 +1,61 @@
+package auth
+
+import "testing"
+
+func TestTestModeEnvironments(t *testing.T) {
+	tests := []struct {
+		env       string
+		wantPanic bool
+	}{
+		{env: envLocal},
+		{env: envTest},
+		{env: envProd, wantPanic: true},
+		{env: "dev", wantPanic: true},
+		{env: "", wantPanic: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("env "+tt.env, func(t *testing.T) {
+			defer func() {
+				if panicked := recover() != nil; panicked != tt.wantPanic {
+					t.Fatalf("panicked = %v, want %v", panicked, tt.wantPanic)
+				}
+			}()
+
+			newFixture(t, WithEnv(tt.env), WithTestMode(1))
+		})
+	}
+}
+
+func TestTestModeIsReproducible(t *testing.T) {
+	loginWithSeed := func(seed int64) *LoginResult {
+		f := newFixture(t, WithEnv(envTest), WithTestMode(seed))
+		f.addUser(t, "user@example.com", testPassword)
+
+		return f.login(t, "user@example.com")
+	}
+
+	tests := []struct {
+		name     string
+		seedA    int64
+		seedB    int64
+		wantSame bool
+	}{
+		{name: "same seed", seedA: 7, seedB: 7, wantSame: true},
+		{name: "other seed", seedA: 7, seedB: 8, wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := loginWithSeed(tt.seedA), loginWithSeed(tt.seedB)
+
+			if same := a.Token == b.Token && a.SessionID == b.SessionID; same != tt.wantSame {
+				t.Fatalf("same result = %v, want %v:\n%+v\n%+v", same, tt.wantSame, a, b)
+			}
+
+			if !a.ExpiresAt.Equal(testEpoch.Add(testTokenTTL)) {
+				t.Fatalf("ExpiresAt = %v, want the fixed clock plus the TTL", a.ExpiresAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	jwt "sso/internal/lib"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrInvalidToken            = errors.New("invalid token")
+	ErrTokenExpired            = errors.New("token expired")
+	ErrTokenRevoked            = errors.New("token revoked")
+	ErrFingerprintRequired     = errors.New("client fingerprint required")
+	ErrFingerprintMismatch     = errors.New("client fingerprint mismatch")
+	ErrResourceMismatch        = errors.New("token is not valid for this resource")
+	ErrUntrustedIssuer         = errors.New("token issuer is not trusted")
+	ErrTenantMismatch          = errors.New("token belongs to another tenant")
+	ErrTokenVersionUnsupported = errors.New("token format version is no longer supported")
+)
+
+// TokenFormatVersion is the "ver" claim of issued tokens, bumped whenever
+// the shape of the claims changes. Tokens issued before the claim existed
+// count as version 0.
+const TokenFormatVersion = 1
+
+// WithMinTokenVersion makes ValidateToken reject tokens of an older format
+// version with ErrTokenVersionUnsupported. The IncTokenVersion metric shows
+// when older tokens are no longer in use.
+func WithMinTokenVersion(version int) Option {
+	return func(auth *Auth) {
+		auth.minTokenVersion = version
+	}
+}
+
+// ValidateOption adds checks to a single ValidateToken call.
+type ValidateOption func(options *validateOptions)
+
+type validateOptions struct {
+	resource string
+	scopes   []string
+}
+
+// RequireResource rejects tokens restricted to other resources with
+// ErrResourceMismatch. Tokens without a resource claim are unrestricted.
+func RequireResource(resource string) ValidateOption {
+	return func(options *validateOptions) {
+		options.resource = resource
+	}
+}
+
+// RequireScope rejects tokens whose "scope" claim lacks any of scopes with
+// ErrInsufficientScope.
+func RequireScope(scopes ...string) ValidateOption {
+	return func(options *validateOptions) {
+		options.scopes = append(options.scopes, scopes...)
+	}
+}
+
+// TokenClaims are the verified claims of an access token.
+type TokenClaims struct {
+	UserID    int64
+	Email     string
+	AppID     int32
+	SessionID string
+	TokenID   string
+	Issuer    string
+	TenantID  string
+	// Version is the token format version, see TokenFormatVersion.
+	Version   int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// RefreshAdvised is set for tokens signed with a key being rotated out.
+	// They are still valid, but clients should get a new one.
+	RefreshAdvised bool
+	// Raw holds every claim of the token as decoded from JSON.
+	Raw map[string]any
+}
+
+// ValidateToken verifies a token issued for appID and returns its claims.
+// Tokens bound to a client fingerprint are only accepted from that client,
+// see clientinfo.WithFingerprint.
+func (auth *Auth) ValidateToken(
+	ctx context.Context,
+	tokenString string,
+	appID int32,
+	opts ...ValidateOption,
+) (*TokenClaims, error) {
+	const op = "auth.ValidateToken"
+
+	var options validateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("appID", int(appID)),
+	)
+
+	app, err := auth.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, foreign, err := auth.verificationSecret(log, tokenString, app)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	raw, err := jwt.ParseToken(tokenString, secret)
+
+	refreshAdvised := false
+	if err != nil && !foreign {
+		raw, refreshAdvised = auth.parseWithPreviousSecret(log, tokenString, app)
+	}
+
+	if raw == nil {
+		log.Warn("failed to parse token", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	claims, err := parseClaims(raw)
+	if err != nil || claims.AppID != appID {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	claims.RefreshAdvised = refreshAdvised
+
+	auth.metrics.IncTokenVersion(appID, claims.Version)
+
+	if claims.Version < auth.minTokenVersion {
+		log.Warn("token format version unsupported", slog.Int("version", claims.Version))
+
+		return nil, fmt.Errorf("%s: %w", op, ErrTokenVersionUnsupported)
+	}
+
+	if !auth.now().Before(claims.ExpiresAt) {
+		return nil, fmt.Errorf("%s: %w", op, ErrTokenExpired)
+	}
+
+	if !app.TokensValidAfter.IsZero() && claims.IssuedAt.Before(app.TokensValidAfter) {
+		log.Info("token issued before app threshold", slog.Int64("userID", claims.UserID))
+
+		return nil, fmt.Errorf("%s: %w", op, ErrTokenRevoked)
+	}
+
+	bound, isBound := jwt.Fingerprint(raw)
+	if app.BindFingerprint || isBound {
+		presented, _ := clientinfo.Fingerprint(ctx)
+		if !isBound || subtle.ConstantTimeCompare([]byte(bound), []byte(presented)) != 1 {
+			log.Warn("client fingerprint mismatch", slog.Int64("userID", claims.UserID))
+
+			return nil, fmt.Errorf("%s: %w", op, ErrFingerprintMismatch)
+		}
+	}
+
+	if options.resource != "" {
+		if _, restricted := raw[jwt.ClaimResource]; restricted && !jwt.ClaimContains(raw, jwt.ClaimResource, options.resource) {
+			log.Warn("token used for another resource", slog.String("resource", options.resource))
+
+			return nil, fmt.Errorf("%s: %w", op, ErrResourceMismatch)
+		}
+	}
+
+	for _, scope := range options.scopes {
+		if !jwt.ClaimContains(raw, jwt.ClaimScope, scope) {
+			log.Warn("token lacks required scope", slog.String("scope", scope))
+
+			return nil, fmt.Errorf("%s: %w", op, ErrInsufficientScope)
+		}
+	}
+
+	if foreign {
+		log.Info("token from trusted issuer validated", slog.String("issuer", claims.Issuer))
+
+		return claims, nil
+	}
+
+	if err := auth.checkTokenRevoked(ctx, claims.TokenID); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			log.Warn("token is revoked", slog.String("tokenID", claims.TokenID))
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check token revocation", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.checkSession(ctx, claims.SessionID); err != nil {
+		if errors.Is(err, ErrSessionNotActive) {
+			log.Warn("session is not active", slog.String("sessionID", claims.SessionID))
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check session", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims, nil
+}
+
+// WithValidationCacheTTL caps the TTL ValidateWithTTL reports, bounding how
+// long a revocation can go unnoticed by caches.
+func WithValidationCacheTTL(maxTTL time.Duration) Option {
+	return func(auth *Auth) {
+		auth.validationCacheTTL = maxTTL
+	}
+}
+
+// ValidateWithTTL validates the token like ValidateToken and also returns
+// how long the result may be cached: the token's remaining lifetime, capped
+// by WithValidationCacheTTL. It never exceeds the time until expiry.
+func (auth *Auth) ValidateWithTTL(
+	ctx context.Context,
+	tokenString string,
+	appID int32,
+	opts ...ValidateOption,
+) (*TokenClaims, time.Duration, error) {
+	const op = "auth.ValidateWithTTL"
+
+	claims, err := auth.ValidateToken(ctx, tokenString, appID, opts...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := claims.ExpiresAt.Sub(auth.now())
+	if auth.validationCacheTTL > 0 {
+		ttl = min(ttl, auth.validationCacheTTL)
+	}
+
+	return claims, max(ttl, 0), nil
+}
+
+// ValidateForTenant validates the token like ValidateToken and also requires
+// it to belong to tenantID. Tokens without a tenant never match.
+func (auth *Auth) ValidateForTenant(
+	ctx context.Context,
+	tokenString string,
+	appID int32,
+	tenantID string,
+	opts ...ValidateOption,
+) (*TokenClaims, error) {
+	const op = "auth.ValidateForTenant"
+
+	claims, err := auth.ValidateToken(ctx, tokenString, appID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.TenantID == "" || claims.TenantID != tenantID {
+		auth.log.Warn("token used for another tenant",
+			slog.String("op", op),
+			slog.Int64("userID", claims.UserID),
+			slog.String("tenantID", tenantID),
+		)
+
+		return nil, fmt.Errorf("%s: %w", op, ErrTenantMismatch)
+	}
+
+	return claims, nil
+}
+
+// parseWithPreviousSecret verifies tokenString against the app's previous
+// secret. ok is set when that succeeds.
+func (auth *Auth) parseWithPreviousSecret(log *slog.Logger, tokenString string, app *models.App) (raw map[string]any, ok bool) {
+	previous, err := auth.previousAppSecret(app)
+	if err != nil {
+		log.Error("failed to derive previous app secret", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil, false
+	}
+
+	if previous == "" {
+		return nil, false
+	}
+
+	raw, err = jwt.ParseToken(tokenString, previous)
+	if err != nil {
+		return nil, false
+	}
+
+	log.Info("token signed with previous secret, refresh advised")
+
+	return raw, true
+}
+
+// newToken signs an access token for user in app, issued at now and valid
+// for ttl. Callers derive the expiry they report from the same now, so it
+// matches the token's "exp".
+func (auth *Auth) newToken(
+	log *slog.Logger,
+	user *models.User,
+	app *models.App,
+	now time.Time,
+	ttl time.Duration,
+	opts ...jwt.Option,
+) (string, error) {
+	if err := auth.clockRollback.check(log, now); err != nil {
+		return "", err
+	}
+
+	secret, err := auth.appSecret(app)
+	if err != nil {
+		return "", err
+	}
+
+	signing := *app
+	signing.Secret = secret
+
+	opts = append(opts, auth.instanceTokenOptions()...)
+
+	return jwt.NewToken(user, &signing, now, ttl, opts...)
+}
+
+// instanceTokenOptions returns the claims every token of this instance
+// carries.
+func (auth *Auth) instanceTokenOptions() []jwt.Option {
+	opts := []jwt.Option{jwt.WithVersion(TokenFormatVersion)}
+
+	if auth.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(auth.issuer))
+	}
+
+	return opts
+}
+
+// parseClaims converts decoded JWT claims into TokenClaims. JSON numbers
+// are decoded as float64.
+func parseClaims(raw map[string]any) (*TokenClaims, error) {
+	userID, ok := raw[jwt.ClaimUserID].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	appID, ok := raw[jwt.ClaimAppID].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	exp, ok := raw[jwt.ClaimExpiresAt].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	email, _ := raw[jwt.ClaimEmail].(string)
+	sessionID, _ := raw[jwt.ClaimSessionID].(string)
+	tokenID, _ := raw[jwt.ClaimTokenID].(string)
+	issuer, _ := raw[jwt.ClaimIssuer].(string)
+	tenantID, _ := raw[jwt.ClaimTenantID].(string)
+	version, _ := raw[jwt.ClaimVersion].(float64)
+
+	var issuedAt time.Time
+	if iat, ok := raw[jwt.ClaimIssuedAt].(float64); ok {
+		issuedAt = time.Unix(int64(iat), 0)
+	}
+
+	return &TokenClaims{
+		UserID:    int64(userID),
+		Email:     email,
+		AppID:     int32(appID),
+		SessionID: sessionID,
+		TokenID:   tokenID,
+		Issuer:    issuer,
+		TenantID:  tenantID,
+		Version:   int(version),
+		IssuedAt:  issuedAt,
+		ExpiresAt: time.Unix(int64(exp), 0),
+		Raw:       raw,
+	}, nil
+}
+
+// SupportedClaims returns the sorted names of the claims tokens issued with
+// the current configuration may carry. Claims that depend on per-app
+// settings, user attributes or login options, such as "cnf" or "resource",
+// are included since some app, user or request may enable them. The list
+// is built by the same code that issues tokens, so it can't drift from it.
+func (auth *Auth) SupportedClaims() []string {
+	const probe = "probe"
+
+	user := &models.User{Roles: []string{probe}, TenantID: probe}
+	app := &models.App{BindFingerprint: true, RoleScopes: map[string][]string{probe: {probe}}}
+	ctx := clientinfo.WithFingerprint(context.Background(), probe)
+
+	// tokenOptions only fails without a client fingerprint, which ctx has.
+	opts, _ := auth.tokenOptions(ctx, auth.log, user, app, probe, probe, []string{probe})
+
+	// Downstream tokens also name the app they were exchanged from.
+	opts = append(opts, jwt.WithAuthorizedParty(0))
+	opts = append(opts, auth.instanceTokenOptions()...)
+
+	return jwt.ClaimNames(opts...)
+}
@@ -0,0 +1,384 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sso/internal/domain/models"
+	jwt "sso/internal/lib"
+	"sso/internal/lib/clientinfo"
+	"testing"
+	"time"
+)
+
+func TestFingerprintBoundTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		// loginFP and validateFP are the client fingerprints of the login
+		// and the validation, empty for none.
+		loginFP    string
+		validateFP string
+		bind       bool
+		wantErr    error
+	}{
+		{name: "same client", bind: true, loginFP: "fp-1", validateFP: "fp-1"},
+		{name: "other client", bind: true, loginFP: "fp-1", validateFP: "fp-2", wantErr: ErrFingerprintMismatch},
+		{name: "no fingerprint presented", bind: true, loginFP: "fp-1", wantErr: ErrFingerprintMismatch},
+		{name: "unbound app ignores fingerprint", loginFP: "fp-1", validateFP: "fp-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.apps.update(testAppID, func(app *models.App) { app.BindFingerprint = tt.bind })
+			f.addUser(t, "user@example.com", testPassword)
+
+			ctx := clientinfo.WithFingerprint(context.Background(), tt.loginFP)
+
+			result, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID)
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			ctx = clientinfo.WithFingerprint(context.Background(), tt.validateFP)
+
+			if _, err := f.auth.ValidateToken(ctx, result.Token, testAppID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoginRequiresFingerprintForBindingApp(t *testing.T) {
+	f := newFixture(t)
+	f.apps.update(testAppID, func(app *models.App) { app.BindFingerprint = true })
+	f.addUser(t, "user@example.com", testPassword)
+
+	_, err := f.auth.Login(context.Background(), "user@example.com", testPassword, testAppID)
+	if !errors.Is(err, ErrFingerprintRequired) {
+		t.Fatalf("Login() error = %v, want %v", err, ErrFingerprintRequired)
+	}
+}
+
+func TestUnboundTokenRejectedOnceAppBinds(t *testing.T) {
+	f := newFixture(t)
+	f.addUser(t, "user@example.com", testPassword)
+
+	result := f.login(t, "user@example.com")
+
+	f.apps.update(testAppID, func(app *models.App) { app.BindFingerprint = true })
+
+	ctx := clientinfo.WithFingerprint(context.Background(), "fp-1")
+	if _, err := f.auth.ValidateToken(ctx, result.Token, testAppID); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Fatalf("ValidateToken() error = %v, want %v", err, ErrFingerprintMismatch)
+	}
+}
+
+func TestRequireResource(t *testing.T) {
+	const (
+		orders   = "https://orders.example.com"
+		payments = "https://payments.example.com"
+	)
+
+	tests := []struct {
+		name      string
+		resources []string
+		require   string
+		wantErr   error
+	}{
+		{name: "granted resource", resources: []string{orders, payments}, require: payments},
+		{name: "other resource", resources: []string{orders}, require: payments, wantErr: ErrResourceMismatch},
+		{name: "unrestricted token", require: payments},
+		{name: "no requirement", resources: []string{orders}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.addUser(t, "user@example.com", testPassword)
+
+			result := f.login(t, "user@example.com", LoginForResources(tt.resources...))
+
+			var opts []ValidateOption
+			if tt.require != "" {
+				opts = append(opts, RequireResource(tt.require))
+			}
+
+			if _, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID, opts...); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupportedClaims(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantClaims  []string
+		wantMissing []string
+	}{
+		{
+			name:        "defaults",
+			wantClaims:  []string{jwt.ClaimUserID, jwt.ClaimEmail, jwt.ClaimExpiresAt, jwt.ClaimAppID, jwt.ClaimScope, jwt.ClaimConfirmation},
+			wantMissing: []string{jwt.ClaimRoles, jwt.ClaimIssuer},
+		},
+		{
+			name:       "roles and issuer",
+			opts:       []Option{WithRolesClaim(), WithIssuer("https://sso.example.com")},
+			wantClaims: []string{jwt.ClaimRoles, jwt.ClaimIssuer},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, append([]Option{WithSessionStore(newFakeSessions())}, tt.opts...)...)
+			f.apps.update(testAppID, func(app *models.App) {
+				app.BindFingerprint = true
+				app.RoleScopes = map[string][]string{"editor": {"docs:write"}}
+			})
+
+			user := f.addUser(t, "user@example.com", testPassword)
+			if err := f.users.update(int64(user.Id), user.Version, func(user *models.User) { user.Roles = []string{"editor"} }); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+
+			supported := f.auth.SupportedClaims()
+
+			if !slices.IsSorted(supported) {
+				t.Errorf("SupportedClaims() = %v, want it sorted", supported)
+			}
+
+			for _, claim := range tt.wantClaims {
+				if !slices.Contains(supported, claim) {
+					t.Errorf("SupportedClaims() = %v, want %q", supported, claim)
+				}
+			}
+
+			for _, claim := range tt.wantMissing {
+				if slices.Contains(supported, claim) {
+					t.Errorf("SupportedClaims() = %v, want no %q", supported, claim)
+				}
+			}
+
+			// Every claim of an issued token must be listed.
+			ctx := clientinfo.WithFingerprint(context.Background(), "fp")
+
+			result, err := f.auth.Login(ctx, "user@example.com", testPassword, testAppID)
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			claims, err := f.auth.ValidateToken(ctx, result.Token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			for claim := range claims.Raw {
+				if !slices.Contains(supported, claim) {
+					t.Errorf("issued claim %q is missing from SupportedClaims() = %v", claim, supported)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshAdvisedForPreviousSecret(t *testing.T) {
+	f := newFixture(t, WithSessionStore(newFakeSessions()), WithRotationStore(&fakeRotations{}))
+	f.addUser(t, "user@example.com", testPassword)
+
+	old := f.login(t, "user@example.com").Token
+
+	f.apps.update(testAppID, func(app *models.App) {
+		app.PreviousSecret = app.Secret
+		app.Secret = "rotated-secret"
+	})
+
+	current := f.login(t, "user@example.com").Token
+
+	refreshed, err := f.auth.RefreshToken(context.Background(), old, testAppID)
+	if err != nil {
+		t.Fatalf("RefreshToken(old): %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		wantAdvised bool
+	}{
+		{name: "signed with previous secret", token: old, wantAdvised: true},
+		{name: "signed with current secret", token: current},
+		{name: "refreshed", token: refreshed.Token},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := f.auth.ValidateToken(context.Background(), tt.token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			if claims.RefreshAdvised != tt.wantAdvised {
+				t.Fatalf("RefreshAdvised = %v, want %v", claims.RefreshAdvised, tt.wantAdvised)
+			}
+		})
+	}
+
+	// Once the previous secret is dropped, its tokens are rejected.
+	f.apps.update(testAppID, func(app *models.App) { app.PreviousSecret = "" })
+
+	if _, err := f.auth.ValidateToken(context.Background(), current, testAppID); err != nil {
+		t.Fatalf("ValidateToken(current) error = %v, want nil", err)
+	}
+
+	if _, err := f.auth.ValidateToken(context.Background(), old, testAppID); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ValidateToken(old) error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestValidateForTenant(t *testing.T) {
+	f := newFixture(t)
+
+	acme := f.addUser(t, "acme@example.com", testPassword)
+	if err := f.users.update(int64(acme.Id), acme.Version, func(user *models.User) { user.TenantID = "acme" }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	f.addUser(t, "solo@example.com", testPassword)
+
+	acmeToken := f.login(t, "acme@example.com").Token
+	soloToken := f.login(t, "solo@example.com").Token
+
+	tests := []struct {
+		name    string
+		token   string
+		tenant  string
+		wantErr error
+	}{
+		{name: "own tenant", token: acmeToken, tenant: "acme"},
+		{name: "other tenant", token: acmeToken, tenant: "globex", wantErr: ErrTenantMismatch},
+		{name: "token without tenant", token: soloToken, tenant: "acme", wantErr: ErrTenantMismatch},
+		{name: "empty tenant never matches", token: soloToken, tenant: "", wantErr: ErrTenantMismatch},
+		{name: "invalid token", token: "not-a-token", tenant: "acme", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := f.auth.ValidateForTenant(context.Background(), tt.token, testAppID, tt.tenant)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateForTenant() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && claims.TenantID != tt.tenant {
+				t.Fatalf("ValidateForTenant() tenant = %q, want %q", claims.TenantID, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestValidateWithTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		elapsed time.Duration
+		want    time.Duration
+		wantErr error
+	}{
+		{name: "remaining lifetime", elapsed: 20 * time.Minute, want: 40 * time.Minute},
+		{name: "capped", opts: []Option{WithValidationCacheTTL(time.Minute)}, elapsed: 20 * time.Minute, want: time.Minute},
+		{name: "cap above the remaining lifetime", opts: []Option{WithValidationCacheTTL(time.Hour)}, elapsed: 50 * time.Minute, want: 10 * time.Minute},
+		{name: "last second", elapsed: testTokenTTL - time.Second, want: time.Second},
+		{name: "expired", elapsed: testTokenTTL, wantErr: ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.opts...)
+			f.addUser(t, "user@example.com", testPassword)
+
+			token := f.login(t, "user@example.com").Token
+			f.clock.Advance(tt.elapsed)
+
+			_, ttl, err := f.auth.ValidateWithTTL(context.Background(), token, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateWithTTL() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if ttl != tt.want {
+				t.Fatalf("ValidateWithTTL() ttl = %v, want %v", ttl, tt.want)
+			}
+		})
+	}
+}
+
+// legacyToken returns a token for user without the "ver" claim, as issued
+// before token format versions.
+func legacyToken(t *testing.T, user *models.User) string {
+	t.Helper()
+
+	token, err := jwt.NewToken(user, &models.App{Id: testAppID, Secret: testAppSecret}, testEpoch, testTokenTTL)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	return token
+}
+
+func TestMinTokenVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		minVersion int
+		legacy     bool
+		wantErr    error
+	}{
+		{name: "no minimum, current token"},
+		{name: "no minimum, legacy token", legacy: true},
+		{name: "current token at minimum", minVersion: TokenFormatVersion},
+		{name: "legacy token below minimum", minVersion: TokenFormatVersion, legacy: true, wantErr: ErrTokenVersionUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, WithMinTokenVersion(tt.minVersion))
+			user := f.addUser(t, "user@example.com", testPassword)
+
+			token := f.login(t, "user@example.com").Token
+			if tt.legacy {
+				token = legacyToken(t, user)
+			}
+
+			claims, err := f.auth.ValidateToken(context.Background(), token, testAppID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && tt.legacy != (claims.Version == 0) {
+				t.Fatalf("claims.Version = %d, want legacy %v", claims.Version, tt.legacy)
+			}
+		})
+	}
+}
+
+func TestTokenVersionMetric(t *testing.T) {
+	metrics := newFakeMetrics()
+	f := newFixture(t, WithMetrics(metrics), WithMinTokenVersion(TokenFormatVersion))
+	user := f.addUser(t, "user@example.com", testPassword)
+
+	tokens := []string{
+		f.login(t, "user@example.com").Token,
+		f.login(t, "user@example.com").Token,
+		legacyToken(t, user),
+	}
+
+	// Rejected versions are counted too, they are what still needs to
+	// migrate.
+	for _, token := range tokens {
+		_, _ = f.auth.ValidateToken(context.Background(), token, testAppID)
+	}
+
+	want := map[int]int{0: 1, TokenFormatVersion: 2}
+	if !maps.Equal(metrics.tokenVersions, want) {
+		t.Fatalf("token versions = %v, want %v", metrics.tokenVersions, want)
+	}
+}
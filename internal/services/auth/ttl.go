@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"log/slog"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// TTLBounds limits a token lifetime. Zero leaves that side unbounded.
+type TTLBounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// WithAccessTTLBounds clamps access token TTLs configured per app.
+func WithAccessTTLBounds(bounds TTLBounds) Option {
+	return func(auth *Auth) {
+		auth.accessTTLBounds = bounds
+	}
+}
+
+// WithRefreshTTLBounds clamps session (refresh) lifetimes configured per app.
+func WithRefreshTTLBounds(bounds TTLBounds) Option {
+	return func(auth *Auth) {
+		auth.refreshTTLBounds = bounds
+	}
+}
+
+// accessTTL returns the access token TTL for user in app: the user's
+// TokenTTL if set, otherwise the app's AccessTTL if set, otherwise the
+// service default, clamped to the access bounds.
+func (auth *Auth) accessTTL(app *models.App, user *models.User) time.Duration {
+	ttl := auth.tokenTTL
+	if app.AccessTTL > 0 {
+		ttl = app.AccessTTL
+	}
+
+	if user.TokenTTL != nil && *user.TokenTTL > 0 {
+		ttl = *user.TokenTTL
+	}
+
+	return auth.clampTTL(ttl, auth.accessTTLBounds, app, "access")
+}
+
+// refreshTTL returns the session lifetime for app: its RefreshTTL if set,
+// otherwise the configured max session lifetime or the token TTL, clamped
+// to the refresh bounds.
+func (auth *Auth) refreshTTL(app *models.App) time.Duration {
+	ttl := auth.tokenTTL
+	if auth.sessionTimeouts.maxLifetime > 0 {
+		ttl = auth.sessionTimeouts.maxLifetime
+	}
+
+	if app.RefreshTTL > 0 {
+		ttl = app.RefreshTTL
+	}
+
+	return auth.clampTTL(ttl, auth.refreshTTLBounds, app, "refresh")
+}
+
+func (auth *Auth) clampTTL(ttl time.Duration, bounds TTLBounds, app *models.App, kind string) time.Duration {
+	clamped := ttl
+
+	if bounds.Min > 0 {
+		clamped = max(clamped, bounds.Min)
+	}
+
+	if bounds.Max > 0 {
+		clamped = min(clamped, bounds.Max)
+	}
+
+	if clamped != ttl {
+		auth.log.Warn("token TTL out of bounds, clamped",
+			slog.Int("appID", int(app.Id)),
+			slog.String("kind", kind),
+			slog.Duration("ttl", ttl),
+			slog.Duration("clamped", clamped),
+		)
+	}
+
+	return clamped
+}
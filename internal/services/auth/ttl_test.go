@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+)
+
+func TestLoginTTLs(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		accessTTL   time.Duration
+		refreshTTL  time.Duration
+		userTTL     time.Duration
+		wantAccess  time.Duration
+		wantSession time.Duration
+	}{
+		{
+			name:        "service defaults",
+			wantAccess:  testTokenTTL,
+			wantSession: testTokenTTL,
+		},
+		{
+			name:        "max session lifetime",
+			opts:        []Option{WithSessionTimeouts(0, 24*time.Hour)},
+			wantAccess:  testTokenTTL,
+			wantSession: 24 * time.Hour,
+		},
+		{
+			name:        "app TTLs",
+			opts:        []Option{WithSessionTimeouts(0, 24*time.Hour)},
+			accessTTL:   5 * time.Minute,
+			refreshTTL:  7 * 24 * time.Hour,
+			wantAccess:  5 * time.Minute,
+			wantSession: 7 * 24 * time.Hour,
+		},
+		{
+			name: "clamped to bounds",
+			opts: []Option{
+				WithAccessTTLBounds(TTLBounds{Min: time.Minute, Max: 30 * time.Minute}),
+				WithRefreshTTLBounds(TTLBounds{Min: 2 * time.Hour, Max: 48 * time.Hour}),
+			},
+			accessTTL:   time.Second,
+			refreshTTL:  30 * 24 * time.Hour,
+			wantAccess:  time.Minute,
+			wantSession: 48 * time.Hour,
+		},
+		{
+			name:        "user TTL overrides the app",
+			accessTTL:   5 * time.Minute,
+			userTTL:     2 * time.Minute,
+			wantAccess:  2 * time.Minute,
+			wantSession: testTokenTTL,
+		},
+		{
+			name:        "user TTL is clamped",
+			opts:        []Option{WithAccessTTLBounds(TTLBounds{Min: time.Minute})},
+			userTTL:     time.Second,
+			wantAccess:  time.Minute,
+			wantSession: testTokenTTL,
+		},
+		{
+			name:        "default below the minimum",
+			opts:        []Option{WithRefreshTTLBounds(TTLBounds{Min: 2 * time.Hour})},
+			wantAccess:  testTokenTTL,
+			wantSession: 2 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, append([]Option{WithSessionStore(newFakeSessions())}, tt.opts...)...)
+			f.apps.update(testAppID, func(app *models.App) {
+				app.AccessTTL = tt.accessTTL
+				app.RefreshTTL = tt.refreshTTL
+			})
+			user := f.addUser(t, "user@example.com", testPassword)
+			if tt.userTTL > 0 {
+				setUserTokenTTL(t, f, user, tt.userTTL)
+			}
+
+			result := f.login(t, "user@example.com")
+
+			if got := result.ExpiresAt.Sub(testEpoch); got != tt.wantAccess {
+				t.Errorf("access TTL = %v, want %v", got, tt.wantAccess)
+			}
+
+			if got := result.SessionExpiresAt.Sub(testEpoch); got != tt.wantSession {
+				t.Errorf("session TTL = %v, want %v", got, tt.wantSession)
+			}
+
+			claims, err := f.auth.ValidateToken(context.Background(), result.Token, testAppID)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			if !claims.ExpiresAt.Equal(result.ExpiresAt) {
+				t.Errorf("token exp = %v, want %v", claims.ExpiresAt, result.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestDownstreamTokenUsesUserTTL(t *testing.T) {
+	f := newFixture(t)
+	f.apps.add(models.App{Id: downstreamAppID, Secret: "downstream-secret", AccessTTL: 30 * time.Minute})
+	user := f.addUser(t, "user@example.com", testPassword)
+	setUserTokenTTL(t, f, user, 10*time.Minute)
+
+	source, err := f.auth.ValidateToken(context.Background(), f.login(t, "user@example.com").Token, testAppID)
+	if err != nil {
+		t.Fatalf("ValidateToken(source): %v", err)
+	}
+
+	token, err := f.auth.IssueDownstreamToken(context.Background(), source, downstreamAppID)
+	if err != nil {
+		t.Fatalf("IssueDownstreamToken: %v", err)
+	}
+
+	claims, err := f.auth.ValidateToken(context.Background(), token, downstreamAppID)
+	if err != nil {
+		t.Fatalf("ValidateToken(downstream): %v", err)
+	}
+
+	if got := claims.ExpiresAt.Sub(testEpoch); got != 10*time.Minute {
+		t.Fatalf("downstream TTL = %v, want the user's 10m", got)
+	}
+}
+
+func setUserTokenTTL(t *testing.T, f *fixture, user *models.User, ttl time.Duration) {
+	t.Helper()
+
+	if err := f.users.update(int64(user.Id), user.Version, func(user *models.User) { user.TokenTTL = &ttl }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var ErrInvalidConfig = errors.New("invalid auth service config")
+
+// minCSRFKeyLen is the shortest accepted CSRF key, in bytes.
+const minCSRFKeyLen = 16
+
+// Validate checks the service configuration so that misconfiguration is
+// caught at startup rather than on the first request. All problems are
+// reported at once, each wrapping ErrInvalidConfig.
+func (auth *Auth) Validate() error {
+	var errs []error
+
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...))
+	}
+
+	if auth.log == nil {
+		invalid("logger is nil")
+	}
+
+	if auth.userSaver == nil {
+		invalid("user saver is nil")
+	}
+
+	if auth.userProvider == nil {
+		invalid("user provider is nil")
+	}
+
+	if auth.appProvider == nil {
+		invalid("app provider is nil")
+	}
+
+	if auth.hasher == nil {
+		invalid("password hasher is nil")
+	}
+
+	if auth.now == nil {
+		invalid("clock is nil")
+	}
+
+	if auth.metrics == nil {
+		invalid("metrics is nil")
+	}
+
+	if auth.tokenTTL <= 0 {
+		invalid("token TTL must be positive, got %s", auth.tokenTTL)
+	}
+
+	checkBounds := func(name string, bounds TTLBounds) {
+		if bounds.Min < 0 || bounds.Max < 0 || bounds.Max > 0 && bounds.Min > bounds.Max {
+			invalid("%s TTL bounds are invalid: min %s, max %s", name, bounds.Min, bounds.Max)
+		}
+	}
+
+	checkBounds("access", auth.accessTTLBounds)
+	checkBounds("refresh", auth.refreshTTLBounds)
+
+	if auth.csrfKey != nil && len(auth.csrfKey) < minCSRFKeyLen {
+		invalid("csrf key must be at least %d bytes", minCSRFKeyLen)
+	}
+
+	if auth.masterKey != nil && len(auth.masterKey) < minMasterKeyLen {
+		invalid("master key must be at least %d bytes", minMasterKeyLen)
+	}
+
+	if auth.previousMasterKey != nil && (auth.masterKey == nil || len(auth.previousMasterKey) < minMasterKeyLen) {
+		invalid("previous master key needs a master key and must be at least %d bytes", minMasterKeyLen)
+	}
+
+	if (auth.analyticsKey != nil || auth.pseudonymClaim) && len(auth.analyticsKey) < minAnalyticsKeyLen {
+		invalid("analytics key must be at least %d bytes", minAnalyticsKeyLen)
+	}
+
+	if auth.peppers.current != 0 && auth.peppers.versions[auth.peppers.current] == nil {
+		invalid("current pepper version %d is not configured", auth.peppers.current)
+	}
+
+	for _, version := range slices.Sorted(maps.Keys(auth.peppers.versions)) {
+		if pepper := auth.peppers.versions[version]; version <= 0 || len(pepper) < minPepperLen {
+			invalid("pepper version %d must be positive and at least %d bytes", version, minPepperLen)
+		}
+	}
+
+	if auth.minTokenVersion > TokenFormatVersion {
+		invalid("min token version %d is above the issued version %d", auth.minTokenVersion, TokenFormatVersion)
+	}
+
+	if auth.passwordPolicy.MinLength < 0 {
+		invalid("password min length must not be negative, got %d", auth.passwordPolicy.MinLength)
+	}
+
+	if auth.clockRollback.threshold < 0 {
+		invalid("clock rollback threshold must not be negative, got %s", auth.clockRollback.threshold)
+	}
+
+	if auth.sessionTimeouts.idle < 0 || auth.sessionTimeouts.maxLifetime < 0 {
+		invalid("session timeouts must not be negative")
+	}
+
+	if auth.captcha != nil && (auth.captcha.failures == nil || auth.captcha.threshold < 1) {
+		invalid("captcha needs a failure counter and a positive threshold")
+	}
+
+	if auth.verification != nil && (auth.verification.notifier == nil || auth.userDeleter == nil) {
+		invalid("verification notifier needs a notifier and a user deleter for rollbacks")
+	}
+
+	if auth.domainCheck != nil && auth.domainCheck.Resolver == nil {
+		invalid("email domain check needs a resolver")
+	}
+
+	return errors.Join(errs...)
+}
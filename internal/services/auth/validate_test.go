@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"sso/internal/lib/ratelimit"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// change alters the Auth after New, for settings without an option.
+		change func(auth *Auth)
+		// wantErr is a substring of the reported problem, empty if the
+		// config is valid.
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "nil logger", change: func(auth *Auth) { auth.log = nil }, wantErr: "logger is nil"},
+		{name: "nil user saver", change: func(auth *Auth) { auth.userSaver = nil }, wantErr: "user saver is nil"},
+		{name: "nil user provider", change: func(auth *Auth) { auth.userProvider = nil }, wantErr: "user provider is nil"},
+		{name: "nil app provider", change: func(auth *Auth) { auth.appProvider = nil }, wantErr: "app provider is nil"},
+		{name: "nil hasher", opts: []Option{WithPasswordHasher(nil)}, wantErr: "password hasher is nil"},
+		{name: "nil clock", opts: []Option{WithClock(nil)}, wantErr: "clock is nil"},
+		{name: "nil metrics", opts: []Option{WithMetrics(nil)}, wantErr: "metrics is nil"},
+		{name: "zero token TTL", change: func(auth *Auth) { auth.tokenTTL = 0 }, wantErr: "token TTL must be positive"},
+		{name: "access TTL bounds", opts: []Option{WithAccessTTLBounds(TTLBounds{Min: time.Minute, Max: time.Hour})}},
+		{name: "inverted access TTL bounds", opts: []Option{WithAccessTTLBounds(TTLBounds{Min: time.Hour, Max: time.Minute})}, wantErr: "access TTL bounds"},
+		{name: "negative refresh TTL bound", opts: []Option{WithRefreshTTLBounds(TTLBounds{Min: -time.Hour})}, wantErr: "refresh TTL bounds"},
+		{name: "short csrf key", opts: []Option{WithCSRFKey([]byte("short"))}, wantErr: "csrf key"},
+		{name: "csrf key", opts: []Option{WithCSRFKey([]byte("0123456789abcdef"))}},
+		{name: "master key", opts: []Option{WithMasterKey(testMasterKey)}},
+		{name: "short master key", opts: []Option{WithMasterKey([]byte("short"))}, wantErr: "master key must be"},
+		{name: "previous master key", opts: []Option{WithMasterKey(testMasterKey), WithPreviousMasterKey(otherMasterKey)}},
+		{name: "previous master key alone", opts: []Option{WithPreviousMasterKey(otherMasterKey)}, wantErr: "previous master key"},
+		{name: "short previous master key", opts: []Option{WithMasterKey(testMasterKey), WithPreviousMasterKey([]byte("short"))}, wantErr: "previous master key"},
+		{name: "peppers", opts: []Option{WithPeppers(2, map[int][]byte{1: firstPepper, 2: secondPepper})}},
+		{name: "current pepper missing", opts: []Option{WithPeppers(2, map[int][]byte{1: firstPepper})}, wantErr: "current pepper version 2"},
+		{name: "short pepper", opts: []Option{WithPeppers(1, map[int][]byte{1: []byte("short")})}, wantErr: "pepper version 1 must be"},
+		{name: "negative pepper version", opts: []Option{WithPeppers(1, map[int][]byte{-1: firstPepper, 1: secondPepper})}, wantErr: "pepper version -1 must be"},
+		{name: "min token version", opts: []Option{WithMinTokenVersion(TokenFormatVersion)}},
+		{name: "min token version above issued", opts: []Option{WithMinTokenVersion(TokenFormatVersion + 1)}, wantErr: "min token version"},
+		{name: "analytics key", opts: []Option{WithAnalyticsKey(testAnalyticsKey, true)}},
+		{name: "short analytics key", opts: []Option{WithAnalyticsKey([]byte("short"), false)}, wantErr: "analytics key"},
+		{name: "claim without analytics key", opts: []Option{WithAnalyticsKey(nil, true)}, wantErr: "analytics key"},
+		{name: "negative min length", opts: []Option{WithPasswordPolicy(PasswordPolicy{MinLength: -1})}, wantErr: "password min length"},
+		{name: "negative idle timeout", opts: []Option{WithSessionTimeouts(-time.Minute, 0)}, wantErr: "session timeouts"},
+		{name: "negative max lifetime", opts: []Option{WithSessionTimeouts(0, -time.Minute)}, wantErr: "session timeouts"},
+		{name: "captcha without failure counter", opts: []Option{WithCaptcha(nil, nil, 3)}, wantErr: "captcha"},
+		{name: "captcha without threshold", opts: []Option{WithCaptcha(nil, ratelimit.NewFailureCounter(time.Hour), 0)}, wantErr: "captcha"},
+		{name: "verification without deleter", opts: []Option{WithVerificationNotifier(&fakeNotifier{}, 1, 0)}, wantErr: "verification notifier"},
+		{name: "verification without notifier", opts: []Option{WithVerificationNotifier(nil, 1, 0), WithUserDeleter(newFakeUsers())}, wantErr: "verification notifier"},
+		{name: "verification", opts: []Option{WithVerificationNotifier(&fakeNotifier{}, 1, 0), WithUserDeleter(newFakeUsers())}},
+		{name: "email domain check", opts: []Option{WithEmailDomainCheck(DomainCheck{Resolver: &fakeResolver{}})}},
+		{name: "email domain check without resolver", opts: []Option{WithEmailDomainCheck(DomainCheck{})}, wantErr: "email domain check"},
+		{name: "negative rollback threshold", opts: []Option{WithClockRollbackDetection(-time.Second, true)}, wantErr: "clock rollback threshold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.opts...)
+			if tt.change != nil {
+				tt.change(f.auth)
+			}
+
+			err := f.auth.Validate()
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	f := newFixture(t, WithCSRFKey([]byte("short")), WithPasswordPolicy(PasswordPolicy{MinLength: -1}))
+	f.auth.tokenTTL = 0
+
+	err := f.auth.Validate()
+
+	for _, want := range []string{"token TTL", "csrf key", "password min length"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want it to mention %q", err, want)
+		}
+	}
+}
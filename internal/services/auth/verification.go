@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrVerificationSendFailed = errors.New("failed to send verification email")
+
+// VerificationNotifier sends the email verification message to new users.
+type VerificationNotifier interface {
+	SendVerification(ctx context.Context, userID int64, email string) error
+}
+
+type verificationConfig struct {
+	notifier VerificationNotifier
+	attempts int
+	backoff  time.Duration
+}
+
+// WithVerificationNotifier makes RegisterNewUser send a verification email,
+// trying up to attempts times with backoff between tries. Failures are only
+// logged, except for apps with RequireEmailVerification registered through
+// RegisterForApp: there the new user is deleted again, see WithUserDeleter,
+// and ErrVerificationSendFailed is returned.
+func WithVerificationNotifier(notifier VerificationNotifier, attempts int, backoff time.Duration) Option {
+	return func(auth *Auth) {
+		auth.verification = &verificationConfig{
+			notifier: notifier,
+			attempts: max(attempts, 1),
+			backoff:  backoff,
+		}
+	}
+}
+
+// RegisterOption customizes a single RegisterNewUser call.
+type RegisterOption func(options *registerOptions)
+
+type registerOptions struct {
+	appID int32
+}
+
+// RegisterForApp names the app the user registers through, applying its
+// registration settings such as RequireEmailVerification.
+func RegisterForApp(appID int32) RegisterOption {
+	return func(options *registerOptions) {
+		options.appID = appID
+	}
+}
+
+// registrationApp returns the app named by RegisterForApp, or nil.
+func (auth *Auth) registrationApp(ctx context.Context, options registerOptions) (*models.App, error) {
+	if options.appID == 0 {
+		return nil, nil
+	}
+
+	app, err := auth.appProvider.App(ctx, options.appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, ErrInvalidAppID
+		}
+
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// sendVerification sends the verification email to the new user. If that
+// fails for an app requiring verification, the user is deleted so no
+// unverifiable account is left behind, provided a user deleter is set.
+func (auth *Auth) sendVerification(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	email string,
+	app *models.App,
+) error {
+	if auth.verification == nil {
+		return nil
+	}
+
+	err := auth.notifyWithRetries(ctx, log, userID, email)
+	if err == nil {
+		return nil
+	}
+
+	if app == nil || !app.RequireEmailVerification {
+		log.Error("failed to send verification email", slog.Attr{Key: "error", Value: slog.StringValue(err.Error())})
+
+		return nil
+	}
+
+	log.Error("failed to send mandatory verification email, rolling back registration",
+		slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+	)
+
+	// Validate reports this setup, but New doesn't enforce it.
+	if auth.userDeleter == nil {
+		log.Error("no user deleter configured, leaving the unverified user in place")
+
+		return ErrVerificationSendFailed
+	}
+
+	// The caller's context may be what made the notifier fail, the rollback
+	// must run regardless.
+	if delErr := auth.userDeleter.DeleteUser(context.WithoutCancel(ctx), userID); delErr != nil {
+		log.Error("failed to roll back registration", slog.Attr{Key: "error", Value: slog.StringValue(delErr.Error())})
+
+		return errors.Join(ErrVerificationSendFailed, delErr)
+	}
+
+	auth.emit(context.WithoutCancel(ctx), models.Event{Type: EventUserDeleted, UserId: userID})
+
+	return ErrVerificationSendFailed
+}
+
+func (auth *Auth) notifyWithRetries(ctx context.Context, log *slog.Logger, userID int64, email string) error {
+	var err error
+
+	for attempt := 1; attempt <= auth.verification.attempts; attempt++ {
+		if err = auth.verification.notifier.SendVerification(ctx, userID, email); err == nil {
+			return nil
+		}
+
+		log.Warn("verification email attempt failed",
+			slog.Int("attempt", attempt),
+			slog.Attr{Key: "error", Value: slog.StringValue(err.Error())},
+		)
+
+		if attempt == auth.verification.attempts {
+			break
+		}
+
+		select {
+		case <-time.After(auth.verification.backoff):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+
+	return err
+}
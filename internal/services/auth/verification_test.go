@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sync"
+	"testing"
+)
+
+// fakeNotifier fails its first failures sends.
+type fakeNotifier struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (n *fakeNotifier) SendVerification(context.Context, int64, string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.calls++
+	if n.calls <= n.failures {
+		return errors.New("smtp unavailable")
+	}
+
+	return nil
+}
+
+func TestRegisterSendsVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		required  bool
+		wantCalls int
+		wantUser  bool
+		wantErr   error
+	}{
+		{name: "sent", wantCalls: 1, wantUser: true},
+		{name: "sent after retry", failures: 2, wantCalls: 3, wantUser: true},
+		{name: "optional verification fails", failures: 3, wantCalls: 3, wantUser: true},
+		{name: "required verification fails", failures: 3, required: true, wantCalls: 3, wantErr: ErrVerificationSendFailed},
+		{name: "required verification sent", failures: 1, required: true, wantCalls: 2, wantUser: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &fakeNotifier{failures: tt.failures}
+
+			f := newFixture(t, WithVerificationNotifier(notifier, 3, 0))
+			WithUserDeleter(f.users)(f.auth)
+			f.apps.update(testAppID, func(app *models.App) { app.RequireEmailVerification = tt.required })
+
+			_, err := f.auth.RegisterNewUser(context.Background(), "user@example.com", testPassword, RegisterForApp(testAppID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterNewUser() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if notifier.calls != tt.wantCalls {
+				t.Errorf("%d sends, want %d", notifier.calls, tt.wantCalls)
+			}
+
+			if got := len(f.users.byID) == 1; got != tt.wantUser {
+				t.Errorf("user kept = %v, want %v", got, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestRegisterForUnknownApp(t *testing.T) {
+	f := newFixture(t, WithVerificationNotifier(&fakeNotifier{}, 1, 0))
+	WithUserDeleter(f.users)(f.auth)
+
+	if _, err := f.auth.RegisterNewUser(context.Background(), "user@example.com", testPassword, RegisterForApp(42)); !errors.Is(err, ErrInvalidAppID) {
+		t.Fatalf("RegisterNewUser() error = %v, want %v", err, ErrInvalidAppID)
+	}
+
+	if len(f.users.byID) != 0 {
+		t.Fatal("user saved for an unknown app")
+	}
+}
+
+func TestRegisterVerificationWithoutDeleter(t *testing.T) {
+	notifier := &fakeNotifier{failures: 1}
+
+	f := newFixture(t, WithVerificationNotifier(notifier, 1, 0))
+	f.apps.update(testAppID, func(app *models.App) { app.RequireEmailVerification = true })
+
+	// Without a deleter the rollback is skipped rather than panicking.
+	if _, err := f.auth.RegisterNewUser(context.Background(), "user@example.com", testPassword, RegisterForApp(testAppID)); !errors.Is(err, ErrVerificationSendFailed) {
+		t.Fatalf("RegisterNewUser() error = %v, want %v", err, ErrVerificationSendFailed)
+	}
+
+	if len(f.users.byID) != 1 {
+		t.Fatalf("%d users, want the unverified user kept", len(f.users.byID))
+	}
+}
+
+func TestRegisterVerificationCancelled(t *testing.T) {
+	notifier := &fakeNotifier{failures: 3}
+
+	f := newFixture(t, WithVerificationNotifier(notifier, 3, testTokenTTL))
+	WithUserDeleter(f.users)(f.auth)
+	f.apps.update(testAppID, func(app *models.App) { app.RequireEmailVerification = true })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The backoff is cut short by the cancelled context, and the rollback
+	// still runs.
+	if _, err := f.auth.RegisterNewUser(ctx, "user@example.com", testPassword, RegisterForApp(testAppID)); !errors.Is(err, ErrVerificationSendFailed) {
+		t.Fatalf("RegisterNewUser() error = %v, want %v", err, ErrVerificationSendFailed)
+	}
+
+	if notifier.calls != 1 || len(f.users.byID) != 0 {
+		t.Fatalf("%d sends and %d users, want 1 send and no users", notifier.calls, len(f.users.byID))
+	}
+}
@@ -6,4 +6,17 @@ var (
 	ErrUserExists   = errors.New("user already exists")
 	ErrUserNotFound = errors.New("user not found")
 	ErrAppNotFound  = errors.New("app not found")
+
+	ErrVersionConflict = errors.New("version conflict")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrAPIKeyNotFound         = errors.New("api key not found")
+	ErrActionTokenNotFound    = errors.New("action token not found")
+	ErrActionTokenUsed        = errors.New("action token already used")
+	ErrInvitationNotFound     = errors.New("invitation not found")
+	ErrInvitationUsed         = errors.New("invitation already used")
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrPepperNotFound         = errors.New("pepper not found")
+	ErrPepperExists           = errors.New("pepper version already exists")
 )